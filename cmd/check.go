@@ -0,0 +1,112 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// checkCmd groups the nagios checks, one subcommand per check mode
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Run a nagios check",
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.AddCommand(
+		newCheckCommand(nagios.MergeRequestsMode, []string{"mrs"}, "Alert on merge requests without activity for too long",
+			addSelectionFlags, addMultiProjectFlags, addLastUpdateFlags, addMergeRequestsFlags, addMessageTemplateFlags, addMetricFlags, addNotifyFlags),
+		newCheckCommand(nagios.CountMode, nil, "Alert on the size of the review backlog",
+			addSelectionFlags, addMultiProjectFlags, addCountFlags, addPerUserFlags, addMetricFlags),
+		newCheckCommand(nagios.ReviewerLoadMode, nil, "Alert on users holding too many merge requests across projects",
+			addSelectionFlags, addMultiProjectFlags, addPerUserFlags, addMetricFlags),
+		newCheckCommand(nagios.ThroughputMode, nil, "Report the number and lead times of the recently merged merge requests",
+			addSelectionFlags, addMultiProjectFlags, addLookbackFlag, addMetricFlags),
+		newCheckCommand(nagios.AbandonedMode, nil, "Report the merge requests recently closed without being merged",
+			addSelectionFlags, addMultiProjectFlags, addLookbackFlag, addAbandonedFlags, addMetricFlags),
+		newCheckCommand(nagios.BranchesMode, nil, "Alert on branches without commit for too long and without merge request",
+			addSelectionFlags, addMultiProjectFlags, addLastUpdateFlags, addBranchesFlags, addMetricFlags),
+		newCheckCommand(nagios.IssuesMode, nil, "Alert on issues without activity for too long",
+			addSelectionFlags, addMultiProjectFlags, addLastUpdateFlags, addIssuesFlags, addMetricFlags),
+	)
+}
+
+// newCheckCommand returns the subcommand running the check in mode
+func newCheckCommand(mode string, aliases []string, short string, flagGroups ...func(*pflag.FlagSet)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     mode,
+		Aliases: aliases,
+		Short:   short,
+		Long:    short + "\n\n" + nagios.FilterFieldsHelp() + "\n" + nagios.MetricsHelp(),
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runCheck(cmd, args, mode)
+		},
+	}
+
+	for _, addFlags := range flagGroups {
+		addFlags(cmd.Flags())
+	}
+	addOutputFlags(cmd.Flags())
+	addSinkFlags(cmd.Flags())
+	addPassiveTemplateFlags(cmd.Flags())
+	addNRDPFlags(cmd.Flags())
+	addCheckmkFlags(cmd.Flags())
+
+	return cmd
+}
+
+// runCheck runs the nagios probe, mode overrides the configured mode unless empty
+func runCheck(cmd *cobra.Command, args []string, mode string) {
+	cfg, err := nagiosConfigViperAdapter()
+	if err != nil {
+		checker := cmd.Context().Value(nagios.CheckerContextKey).(*nagios.Check)
+		checker.Unknownf("invalid configuration: %s", err)
+	}
+	if mode != "" {
+		cfg.Mode = mode
+	}
+	nagios.ProbeCobraAdapter(cmd, args, cfg)
+}
+
+// isCheckCommand returns true if cmd runs a nagios check
+func isCheckCommand(cmd *cobra.Command) bool {
+	return cmd == rootCmd || (cmd.HasParent() && cmd.Parent() == checkCmd)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file and flags without contacting the git provider",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		if err := nagios.ValidateConfig(cfg); err != nil {
+			return err
+		}
+
+		if f := viper.ConfigFileUsed(); f != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: configuration is valid\n", f)
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	// every setting can be validated
+	configValidateCmd.Flags().StringVarP(&cmdFlags.Mode, "mode", "m", nagios.MergeRequestsMode, "check mode to validate the configuration for")
+	addSelectionFlags(configValidateCmd.Flags())
+	addMultiProjectFlags(configValidateCmd.Flags())
+	addLastUpdateFlags(configValidateCmd.Flags())
+	addMergeRequestsFlags(configValidateCmd.Flags())
+	addMessageTemplateFlags(configValidateCmd.Flags())
+	addCountFlags(configValidateCmd.Flags())
+	addPerUserFlags(configValidateCmd.Flags())
+	addLookbackFlag(configValidateCmd.Flags())
+	addAbandonedFlags(configValidateCmd.Flags())
+	addBranchesFlags(configValidateCmd.Flags())
+	addIssuesFlags(configValidateCmd.Flags())
+	addMetricFlags(configValidateCmd.Flags())
+	addOutputFlags(configValidateCmd.Flags())
+	addSinkFlags(configValidateCmd.Flags())
+	addPassiveTemplateFlags(configValidateCmd.Flags())
+	addNRDPFlags(configValidateCmd.Flags())
+	addCheckmkFlags(configValidateCmd.Flags())
+	addNotifyFlags(configValidateCmd.Flags())
+	addNudgeFlags(configValidateCmd.Flags())
+}
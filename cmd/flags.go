@@ -0,0 +1,200 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Flags are grouped by feature so that the root command (which can run
+// every check mode) and each check subcommand register the ones they need.
+// All of them write to cmdFlags and are bound to the viper key of the same name.
+
+func addSelectionFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&cmdFlags.Project, "project", "P", "", "project to check for opened MergeRequests")
+	fs.StringVar(&cmdFlags.TargetBranch, "target-branch", "master", "Only consider merge requests with this target-branch (empty for any target-branch)")
+	fs.StringVar(&cmdFlags.Filter, "filter", "", `Only consider merge requests matching this expression (e.g. '!draft && "security" in labels && age_updated > 2h')`)
+}
+
+func addMultiProjectFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&cmdFlags.Projects, "projects", nil, "[reviewer-load,--output checkmk] projects to check for opened MergeRequests")
+	fs.StringVar(&cmdFlags.Group, "group", "", "[reviewer-load,--output checkmk] check every project of this group (Gitlab group or Github organization)")
+}
+
+func addLastUpdateFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&cmdFlags.WarningLastUpdateDelay, "warning-last-update", 6*time.Hour, "warning if last-update was that delay ago")
+	fs.DurationVar(&cmdFlags.CriticalLastUpdateDelay, "critical-last-update", 24*time.Hour, "critical if last-update was that delay ago")
+}
+
+func addMergeRequestsFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&cmdFlags.CheckConflicts, "check-conflicts", false, "Alert on merge requests that cannot be merged because of conflicts")
+	fs.StringVar(&cmdFlags.ConflictsSeverity, "conflicts-severity", "warning", "Severity of merge requests with conflicts (ok, warning, critical, unknown)")
+	fs.BoolVar(&cmdFlags.CheckFailedPipelines, "check-failed-pipelines", false, "Alert on merge requests whose head pipeline has failed")
+	fs.StringVar(&cmdFlags.FailedPipelinesSeverity, "failed-pipelines-severity", "critical", "Severity of merge requests with a failed pipeline (ok, warning, critical, unknown)")
+}
+
+func addMessageTemplateFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.OKSummaryTemplate, "ok-summary-template", "", "[merge-requests] Go template of the summary when no merge request is too old, including when none is opened (fields: .Project, .MergeRequests)")
+	fs.StringVar(&cmdFlags.ProblemTemplate, "problem-template", "", "[merge-requests] Go template of the line of a merge request without activity for too long (fields: the merge request ones, .LastActivity, .Age, .Status, .Reasons)")
+	fs.StringVar(&cmdFlags.LongOutputTemplate, "long-output-template", "", "[merge-requests] Go template appended to the long output (fields: .Project, .MergeRequests)")
+}
+
+func addCountFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.WarningOpened, "warning-opened", "", "[count] warning range for the number of opened merge requests")
+	fs.StringVar(&cmdFlags.CriticalOpened, "critical-opened", "", "[count] critical range for the number of opened merge requests")
+	fs.DurationVar(&cmdFlags.OlderThan, "older-than", 0, "[count] count merge requests without activity for that delay")
+	fs.StringVar(&cmdFlags.WarningOlder, "warning-older", "", "[count] warning range for the number of merge requests older than --older-than")
+	fs.StringVar(&cmdFlags.CriticalOlder, "critical-older", "", "[count] critical range for the number of merge requests older than --older-than")
+}
+
+func addPerUserFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.PerUser, "per-user", "", fmt.Sprintf("[count,reviewer-load] count merge requests per user holding this role (%s, %s)", nagios.AssigneeRole, nagios.ReviewerRole))
+	fs.StringVar(&cmdFlags.WarningPerUser, "warning-per-user", "", "[count,reviewer-load] warning range for the number of merge requests per user")
+	fs.StringVar(&cmdFlags.CriticalPerUser, "critical-per-user", "", "[count,reviewer-load] critical range for the number of merge requests per user")
+}
+
+func addLookbackFlag(fs *pflag.FlagSet) {
+	fs.DurationVar(&cmdFlags.Lookback, "lookback", 7*24*time.Hour, "[throughput,abandoned] consider the merge requests merged or closed during that delay")
+}
+
+func addAbandonedFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.BotPattern, "bot-pattern", `\[bot\]$|-bot$`, "[abandoned] regexp matching the usernames of bots closing merge requests")
+	fs.StringSliceVar(&cmdFlags.StaleLabels, "stale-labels", []string{"stale"}, "[abandoned] labels set on the merge requests closed by stale rules")
+	fs.BoolVar(&cmdFlags.ListBotClosed, "list-bot-closed", false, "[abandoned] list the merge requests closed by bots or stale rules in the long output")
+}
+
+func addBranchesFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&cmdFlags.ExcludeBranches, "exclude-branches", nil, "[branches] ignore the branches matching these glob patterns (e.g. 'release/*')")
+}
+
+func addIssuesFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&cmdFlags.IssueLabels, "issue-labels", nil, "[issues] only consider issues carrying all these labels")
+	fs.StringVar(&cmdFlags.IssueMilestone, "issue-milestone", "", "[issues] only consider issues of this milestone (title)")
+	fs.StringVar(&cmdFlags.IssueAssignee, "issue-assignee", "", "[issues] only consider issues assigned to this user")
+}
+
+func addOutputFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&cmdFlags.Output, "output", "o", nagios.OutputNagios, fmt.Sprintf("output format can be one of %s", strings.Join(nagios.SupportedOutputs, ",")))
+}
+
+func addSinkFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.GraphiteAddress, "graphite-address", "", "also send the perfdata to this Graphite plaintext endpoint (host:port)")
+	fs.StringVar(&cmdFlags.GraphitePrefix, "graphite-prefix", "git_merge_requests", "prefix of the Graphite metric paths")
+	fs.StringVar(&cmdFlags.InfluxDBURL, "influxdb-url", "", "also post the perfdata to this InfluxDB line protocol write URL (e.g. http://influxdb:8086/write?db=nagios)")
+	fs.StringVar(&cmdFlags.InfluxDBToken, "influxdb-token", "", "token used to authenticate to InfluxDB")
+	fs.StringVar(&cmdFlags.TextfilePath, "textfile-path", "", "also write the perfdata to this file for the node_exporter textfile collector (*.prom)")
+}
+
+func addPassiveFlags(fs *pflag.FlagSet) {
+	addPassiveTemplateFlags(fs)
+	fs.StringVar(&cmdFlags.Icinga2URL, "icinga2-url", "", "submit to the Icinga2 API at this URL (e.g. https://icinga2:5665)")
+	fs.StringVar(&cmdFlags.Icinga2User, "icinga2-user", "", "Icinga2 API user")
+	fs.StringVar(&cmdFlags.Icinga2Password, "icinga2-password", "", "Icinga2 API password")
+	fs.StringVar(&cmdFlags.Icinga2CAFile, "icinga2-ca-file", "", "CA certificate(s) the Icinga2 API certificate is verified with")
+	fs.StringVar(&cmdFlags.CommandFile, "command-file", "", "submit to this Nagios external command file (e.g. /var/lib/nagios4/rw/nagios.cmd)")
+	addNRDPFlags(fs)
+}
+
+func addPassiveTemplateFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.PassiveHostTemplate, "passive-host-template", "{{ .Project }}", "template of the host name of the passive results (fields: .Project, .Name, .Namespace, .Mode)")
+	fs.StringVar(&cmdFlags.PassiveServiceTemplate, "passive-service-template", "git {{ .Mode }}", "template of the service name of the passive results (fields: .Project, .Name, .Namespace, .Mode)")
+}
+
+func addNRDPFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.NRDPURL, "nrdp-url", "", "submit to NRDP at this URL (e.g. https://nagios/nrdp/)")
+	fs.StringVar(&cmdFlags.NRDPToken, "nrdp-token", "", "NRDP token")
+	fs.StringVar(&cmdFlags.NRDPFormat, "nrdp-format", nagios.NRDPFormatJSON, fmt.Sprintf("format of the NRDP submission can be one of %s", strings.Join(nagios.NRDPFormats, ",")))
+	fs.IntVar(&cmdFlags.NRDPRetries, "nrdp-retries", 3, "number of retries of a failed NRDP submission, with exponential backoff")
+	fs.BoolVar(&cmdFlags.NRDPDryRun, "nrdp-dry-run", false, "print the NRDP payload instead of submitting it")
+}
+
+func addCheckmkFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.CheckmkServiceTemplate, "checkmk-service-template", "git {{ .Mode }} {{ .Project }}", "template of the Checkmk service name (fields: .Project, .Name, .Namespace, .Mode)")
+}
+
+func addNotifyFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.NotifyWebhookURL, "notify-webhook-url", "", "[merge-requests] post a digest of the offending merge requests to this Slack, Mattermost or Teams incoming webhook")
+	fs.StringVar(&cmdFlags.NotifyFormat, "notify-format", nagios.NotifyFormatSlack, fmt.Sprintf("[merge-requests] link syntax of the digest can be one of %s", strings.Join(nagios.NotifyFormats, ",")))
+	fs.StringVar(&cmdFlags.NotifyStateFile, "notify-state-file", filepath.Join(os.TempDir(), "nagios-plugin-git-hosted-project-merge-requests-notify.json"), "[merge-requests] file remembering the digests posted")
+	fs.DurationVar(&cmdFlags.NotifyInterval, "notify-interval", 6*time.Hour, "[merge-requests] do not post the same digest again before that delay")
+}
+
+func addNudgeFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.NudgeTemplate, "nudge-template", nagios.DefaultNudgeTemplate, "template of the comment posted on the merge requests (fields: the merge request ones, .LastActivity, .Age)")
+	fs.StringVar(&cmdFlags.NudgeLabel, "nudge-label", "", "also add this label to the merge requests nudged (e.g. stale)")
+	fs.DurationVar(&cmdFlags.NudgeCooldown, "nudge-cooldown", 7*24*time.Hour, "do not nudge a merge request again before that delay")
+	fs.BoolVar(&cmdFlags.DryRun, "dry-run", false, "print what would be done without commenting nor labelling")
+}
+
+func addReportFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&cmdFlags.SMTPAddress, "smtp-address", "", "SMTP relay the digests are sent through (host:port)")
+	fs.StringVar(&cmdFlags.SMTPUser, "smtp-user", "", "SMTP user, no authentication if empty")
+	fs.StringVar(&cmdFlags.SMTPPassword, "smtp-password", "", "SMTP password")
+	fs.BoolVar(&cmdFlags.SMTPStartTLS, "smtp-starttls", true, "require STARTTLS before authenticating and sending")
+	fs.StringVar(&cmdFlags.SMTPFrom, "smtp-from", "", "sender of the digests (e.g. 'Merge requests <noreply@example.com>')")
+	fs.StringVar(&cmdFlags.ReportGroupBy, "report-group-by", nagios.DigestGroupByAuthor, fmt.Sprintf("send the digests to the merge requests %s", strings.Join(nagios.DigestGroupings, " or ")))
+	fs.StringToStringVar(&cmdFlags.ReportRecipients, "report-recipients", nil, "email addresses of the users, as username=address")
+	fs.StringVar(&cmdFlags.ReportEmailDomain, "report-email-domain", "", "send to <username>@<domain> the users without address in --report-recipients")
+	fs.StringSliceVar(&cmdFlags.ReportAllowedDomains, "report-allowed-domains", nil, "only send the digests to these email domains")
+	fs.StringVar(&cmdFlags.ReportSubjectTemplate, "report-subject-template", "", "Go text template of the digest subject (default built-in)")
+	fs.StringVar(&cmdFlags.ReportTextTemplate, "report-text-template", "", "Go text template of the digest plain text body (default built-in)")
+	fs.StringVar(&cmdFlags.ReportHTMLTemplate, "report-html-template", "", "Go html template of the digest HTML body (default built-in)")
+	fs.BoolVar(&cmdFlags.ReportToStdout, "to-stdout", false, "print the digest emails instead of sending them")
+}
+
+func addMetricFlags(fs *pflag.FlagSet) {
+	fs.StringToStringVar(&cmdFlags.WarningMetric, "warning-metric", nil, "warning range of a metric, as metric=range (e.g. median_merge_request_age=86400)")
+	fs.StringToStringVar(&cmdFlags.CriticalMetric, "critical-metric", nil, "critical range of a metric, as metric=range (e.g. merge_requests_age_ge_30d=5)")
+	fs.StringSliceVar(&cmdFlags.CheckMetrics, "check-metric", nil, "only compute the service state from these metrics thresholds (see the list of metrics below)")
+}
+
+// bindFlags binds the flags of the command being executed to viper.
+// Binding has to wait for the command to be known since several commands
+// define flags with the same name.
+func bindFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		viper.BindPFlag(f.Name, f)
+	})
+}
@@ -0,0 +1,215 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+	listFormatCSV   = "csv"
+
+	// longer titles are truncated in the table format
+	listTableTitleWidth = 50
+)
+
+var listFormats = []string{listFormatTable, listFormatJSON, listFormatCSV}
+
+var listFormat string
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the merge requests a check evaluates",
+	Long: `List the merge requests a check evaluates
+
+The same provider query, filter and rules as the checks are used.
+No nagios status is printed and the exit code is 0 unless listing fails.
+
+` + nagios.FilterFieldsHelp(),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !isListFormat(listFormat) {
+			return fmt.Errorf("unsupported format %q (expected one of %s)", listFormat, strings.Join(listFormats, ","))
+		}
+
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		var mr []nagios.MergeRequest
+		if err := withTimeout(cfg, func() error {
+			mr, err = nagios.ListMergeRequests(cfg)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return printMergeRequests(cmd.OutOrStdout(), listFormat, mr, time.Now(), cfg.Locale)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(&listFormat, "format", "f", listFormatTable, fmt.Sprintf("output format can be one of %s", strings.Join(listFormats, ",")))
+	addSelectionFlags(listCmd.Flags())
+	addMultiProjectFlags(listCmd.Flags())
+	addMergeRequestsFlags(listCmd.Flags())
+}
+
+func isListFormat(format string) bool {
+	for _, f := range listFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// listedMergeRequest is a merge request as printed by the list command
+type listedMergeRequest struct {
+	IID          int       `json:"iid"`
+	Project      string    `json:"project"`
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	TargetBranch string    `json:"target_branch"`
+	Draft        bool      `json:"draft"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	AgeCreated   float64   `json:"age_created"` // seconds
+	AgeUpdated   float64   `json:"age_updated"` // seconds
+	Labels       []string  `json:"labels"`
+	WebURL       string    `json:"web_url"`
+}
+
+func newListedMergeRequest(m nagios.MergeRequest, now time.Time) listedMergeRequest {
+	labels := m.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return listedMergeRequest{
+		IID:          m.IID,
+		Project:      m.Project,
+		Title:        m.Title,
+		Author:       m.Author,
+		TargetBranch: m.TargetBranch,
+		Draft:        m.Draft,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+		AgeCreated:   now.Sub(m.CreatedAt).Seconds(),
+		AgeUpdated:   now.Sub(m.UpdatedAt).Seconds(),
+		Labels:       labels,
+		WebURL:       m.WebURL,
+	}
+}
+
+func printMergeRequests(w io.Writer, format string, mr []nagios.MergeRequest, now time.Time, locale string) error {
+	listed := make([]listedMergeRequest, len(mr))
+	for i, m := range mr {
+		listed[i] = newListedMergeRequest(m, now)
+	}
+
+	switch format {
+	case listFormatTable:
+		return printMergeRequestsTable(w, listed, locale)
+	case listFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listed)
+	case listFormatCSV:
+		return printMergeRequestsCSV(w, listed)
+	}
+
+	return fmt.Errorf("unsupported format %q (expected one of %s)", format, strings.Join(listFormats, ","))
+}
+
+func printMergeRequestsTable(w io.Writer, listed []listedMergeRequest, locale string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IID\tPROJECT\tTITLE\tAUTHOR\tCREATED\tUPDATED\tLABELS\tURL")
+	for _, l := range listed {
+		title := l.Title
+		if r := []rune(title); len(r) > listTableTitleWidth {
+			title = string(r[:listTableTitleWidth-3]) + "..."
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			l.IID,
+			l.Project,
+			title,
+			l.Author,
+			nagios.HumanizeDuration(time.Duration(l.AgeCreated*float64(time.Second)), locale),
+			nagios.HumanizeDuration(time.Duration(l.AgeUpdated*float64(time.Second)), locale),
+			strings.Join(l.Labels, ","),
+			l.WebURL,
+		)
+	}
+	return tw.Flush()
+}
+
+func printMergeRequestsCSV(w io.Writer, listed []listedMergeRequest) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"iid", "project", "title", "author", "target_branch", "draft", "created_at", "updated_at", "age_created", "age_updated", "labels", "web_url"})
+	for _, l := range listed {
+		cw.Write([]string{
+			strconv.Itoa(l.IID),
+			l.Project,
+			l.Title,
+			l.Author,
+			l.TargetBranch,
+			strconv.FormatBool(l.Draft),
+			l.CreatedAt.Format(time.RFC3339),
+			l.UpdatedAt.Format(time.RFC3339),
+			strconv.FormatFloat(l.AgeCreated, 'f', 0, 64),
+			strconv.FormatFloat(l.AgeUpdated, 'f', 0, 64),
+			strings.Join(l.Labels, ","),
+			l.WebURL,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+var nudgeCmd = &cobra.Command{
+	Use:   "nudge",
+	Short: "Comment the merge requests without activity for the warning delay",
+	Long: `Comment the merge requests without activity for the warning delay
+
+A comment rendered from --nudge-template is posted on every opened merge
+request of --group, --projects or --project without activity for
+--warning-last-update (or the delay of the first matching rule), and
+--nudge-label is added to it. The comment is a Gitlab note or a Github
+issue comment. It counts as an activity for the checks.
+
+The comments carry a hidden marker: a merge request nudged less than
+--nudge-cooldown ago is skipped. --dry-run prints the comments that would
+be posted without posting them.
+
+The template is a Go template executed with the merge request fields
+(.IID, .Project, .Title, .Author, .WebURL, .TargetBranch, .Labels, ...),
+.LastActivity (time since the last activity) and .Age (time since the
+creation).
+
+` + nagios.FilterFieldsHelp(),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		var nudges []nagios.Nudge
+		if err := withTimeout(cfg, func() error {
+			nudges, err = nagios.NudgeMergeRequests(cfg)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return printNudges(cmd, nudges, cfg.DryRun, cfg.Locale)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(nudgeCmd)
+
+	addSelectionFlags(nudgeCmd.Flags())
+	addMultiProjectFlags(nudgeCmd.Flags())
+	addLastUpdateFlags(nudgeCmd.Flags())
+	addNudgeFlags(nudgeCmd.Flags())
+}
+
+// printNudges prints a table of what was done, followed by the comments with a dry run
+func printNudges(cmd *cobra.Command, nudges []nagios.Nudge, dryRun bool, locale string) error {
+	var failed int
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tIID\tLAST ACTIVITY\tACTION")
+	for _, n := range nudges {
+		var action string
+		switch {
+		case n.Err != nil:
+			failed++
+			action = strings.ReplaceAll(n.Err.Error(), "\n", " ")
+		case n.Skipped():
+			action = fmt.Sprintf("skipped, nudged %s ago", nagios.HumanizeDuration(time.Since(n.PreviousNudge), locale))
+		case dryRun:
+			action = "would comment"
+			if len(n.Labels) > 0 {
+				action += ", label " + strings.Join(n.Labels, ",")
+			}
+		default:
+			action = "commented"
+			if len(n.Labels) > 0 {
+				action += ", labelled " + strings.Join(n.Labels, ",")
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", n.MergeRequest.Project, n.MergeRequest.IID, nagios.HumanizeDuration(n.LastActivity, locale), action)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		for _, n := range nudges {
+			if n.Err == nil && !n.Skipped() {
+				fmt.Fprintf(cmd.OutOrStdout(), "\n%s (%s):\n%s\n", n.MergeRequest.WebURL, n.MergeRequest.Project, n.Comment)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d nudges failed", failed, len(nudges))
+	}
+	return nil
+}
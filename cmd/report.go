@@ -0,0 +1,128 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Email their stale merge requests to the authors or assignees",
+	Long: `Email their stale merge requests to the authors or assignees
+
+The opened merge requests of --group, --projects or --project without
+activity for --warning-last-update (or the delay of the first matching rule)
+are grouped by author or assignee (--report-group-by), and every user gets
+a single digest email through the --smtp-address relay.
+
+Users are emailed at their --report-recipients address, or at
+<username>@--report-email-domain. Users without address, or whose address
+is outside --report-allowed-domains, are skipped.
+
+The subject, plain text and HTML bodies are Go templates executed with
+.User, .Email, .Role and .MergeRequests, whose items have the merge request
+fields (.IID, .Project, .Title, .WebURL, ...), .LastActivity and .Age.
+--to-stdout prints the emails instead of sending them.
+
+` + nagios.FilterFieldsHelp(),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		var deliveries []nagios.DigestDelivery
+		if err := withTimeout(cfg, func() error {
+			deliveries, err = nagios.SendDigests(cfg, cmd.OutOrStdout())
+			return err
+		}); err != nil {
+			return err
+		}
+
+		// the emails own stdout when previewed
+		out := cmd.OutOrStdout()
+		if cfg.ReportToStdout {
+			out = os.Stderr
+		}
+		return printDeliveries(out, deliveries, cfg.ReportToStdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	addSelectionFlags(reportCmd.Flags())
+	addMultiProjectFlags(reportCmd.Flags())
+	addLastUpdateFlags(reportCmd.Flags())
+	addReportFlags(reportCmd.Flags())
+}
+
+// printDeliveries prints a table of the digests sent or skipped
+func printDeliveries(out io.Writer, deliveries []nagios.DigestDelivery, toStdout bool) error {
+	var failed int
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tEMAIL\tMERGE REQUESTS\tDELIVERY")
+	for _, d := range deliveries {
+		delivery := "sent"
+		switch {
+		case d.Err != nil:
+			failed++
+			delivery = strings.ReplaceAll(d.Err.Error(), "\n", " ")
+		case d.Skipped != "":
+			delivery = "skipped, " + d.Skipped
+		case toStdout:
+			delivery = "printed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", d.User, d.Email, len(d.MergeRequests), delivery)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d digests failed", failed, len(deliveries))
+	}
+	return nil
+}
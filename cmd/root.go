@@ -42,6 +42,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
 	"github.com/spf13/cobra"
 
@@ -54,13 +55,86 @@ type rootCmdFlags struct {
 	Host                    string        `mapstructure:"host"`
 	Debug                   bool          `mapstructure:"debug"`
 	Timeout                 time.Duration `mapstructure:"timeout"`
+	Locale                  string        `mapstructure:"locale"`
 	ConfigFile              string
-	GitProvider             string        `mapstructure:"git-provider"`
-	APIToken                string        `mapstructure:"api-token"`
-	Project                 string        `mapstructure:"project"`
-	TargetBranch            string        `mapstructure:"target-branch"`
-	WarningLastUpdateDelay  time.Duration `mapstructure:"delay-warning-last-update"`
-	CriticalLastUpdateDelay time.Duration `mapstructure:"delay-critical-last-update"`
+	GitProvider             string            `mapstructure:"git-provider"`
+	APIToken                string            `mapstructure:"api-token"`
+	Project                 string            `mapstructure:"project"`
+	Projects                []string          `mapstructure:"projects"`
+	Group                   string            `mapstructure:"group"`
+	TargetBranch            string            `mapstructure:"target-branch"`
+	WarningLastUpdateDelay  time.Duration     `mapstructure:"delay-warning-last-update"`
+	CriticalLastUpdateDelay time.Duration     `mapstructure:"delay-critical-last-update"`
+	CheckConflicts          bool              `mapstructure:"check-conflicts"`
+	ConflictsSeverity       string            `mapstructure:"conflicts-severity"`
+	CheckFailedPipelines    bool              `mapstructure:"check-failed-pipelines"`
+	FailedPipelinesSeverity string            `mapstructure:"failed-pipelines-severity"`
+	Filter                  string            `mapstructure:"filter"`
+	OKSummaryTemplate       string            `mapstructure:"ok-summary-template"`
+	ProblemTemplate         string            `mapstructure:"problem-template"`
+	LongOutputTemplate      string            `mapstructure:"long-output-template"`
+	Mode                    string            `mapstructure:"mode"`
+	WarningOpened           string            `mapstructure:"warning-opened"`
+	CriticalOpened          string            `mapstructure:"critical-opened"`
+	OlderThan               time.Duration     `mapstructure:"older-than"`
+	WarningOlder            string            `mapstructure:"warning-older"`
+	CriticalOlder           string            `mapstructure:"critical-older"`
+	PerUser                 string            `mapstructure:"per-user"`
+	WarningPerUser          string            `mapstructure:"warning-per-user"`
+	CriticalPerUser         string            `mapstructure:"critical-per-user"`
+	WarningMetric           map[string]string `mapstructure:"warning-metric"`
+	CriticalMetric          map[string]string `mapstructure:"critical-metric"`
+	CheckMetrics            []string          `mapstructure:"check-metric"`
+	Lookback                time.Duration     `mapstructure:"lookback"`
+	BotPattern              string            `mapstructure:"bot-pattern"`
+	StaleLabels             []string          `mapstructure:"stale-labels"`
+	ListBotClosed           bool              `mapstructure:"list-bot-closed"`
+	ExcludeBranches         []string          `mapstructure:"exclude-branches"`
+	IssueLabels             []string          `mapstructure:"issue-labels"`
+	IssueMilestone          string            `mapstructure:"issue-milestone"`
+	IssueAssignee           string            `mapstructure:"issue-assignee"`
+	Output                  string            `mapstructure:"output"`
+	ListenAddress           string            `mapstructure:"listen-address"`
+	RefreshInterval         time.Duration     `mapstructure:"refresh-interval"`
+	GraphiteAddress         string            `mapstructure:"graphite-address"`
+	GraphitePrefix          string            `mapstructure:"graphite-prefix"`
+	InfluxDBURL             string            `mapstructure:"influxdb-url"`
+	InfluxDBToken           string            `mapstructure:"influxdb-token"`
+	TextfilePath            string            `mapstructure:"textfile-path"`
+	PassiveHostTemplate     string            `mapstructure:"passive-host-template"`
+	PassiveServiceTemplate  string            `mapstructure:"passive-service-template"`
+	Icinga2URL              string            `mapstructure:"icinga2-url"`
+	Icinga2User             string            `mapstructure:"icinga2-user"`
+	Icinga2Password         string            `mapstructure:"icinga2-password"`
+	Icinga2CAFile           string            `mapstructure:"icinga2-ca-file"`
+	CommandFile             string            `mapstructure:"command-file"`
+	NRDPURL                 string            `mapstructure:"nrdp-url"`
+	NRDPToken               string            `mapstructure:"nrdp-token"`
+	NRDPFormat              string            `mapstructure:"nrdp-format"`
+	NRDPRetries             int               `mapstructure:"nrdp-retries"`
+	NRDPDryRun              bool              `mapstructure:"nrdp-dry-run"`
+	CheckmkServiceTemplate  string            `mapstructure:"checkmk-service-template"`
+	NotifyWebhookURL        string            `mapstructure:"notify-webhook-url"`
+	NotifyFormat            string            `mapstructure:"notify-format"`
+	NotifyStateFile         string            `mapstructure:"notify-state-file"`
+	NotifyInterval          time.Duration     `mapstructure:"notify-interval"`
+	NudgeTemplate           string            `mapstructure:"nudge-template"`
+	NudgeLabel              string            `mapstructure:"nudge-label"`
+	NudgeCooldown           time.Duration     `mapstructure:"nudge-cooldown"`
+	DryRun                  bool              `mapstructure:"dry-run"`
+	SMTPAddress             string            `mapstructure:"smtp-address"`
+	SMTPUser                string            `mapstructure:"smtp-user"`
+	SMTPPassword            string            `mapstructure:"smtp-password"`
+	SMTPStartTLS            bool              `mapstructure:"smtp-starttls"`
+	SMTPFrom                string            `mapstructure:"smtp-from"`
+	ReportGroupBy           string            `mapstructure:"report-group-by"`
+	ReportRecipients        map[string]string `mapstructure:"report-recipients"`
+	ReportEmailDomain       string            `mapstructure:"report-email-domain"`
+	ReportAllowedDomains    []string          `mapstructure:"report-allowed-domains"`
+	ReportSubjectTemplate   string            `mapstructure:"report-subject-template"`
+	ReportTextTemplate      string            `mapstructure:"report-text-template"`
+	ReportHTMLTemplate      string            `mapstructure:"report-html-template"`
+	ReportToStdout          bool              `mapstructure:"to-stdout"`
 }
 
 var (
@@ -74,9 +148,14 @@ var (
 var rootCmd = &cobra.Command{
 	Use:   "nagios-plugin-git-hosted-project-merge-requests",
 	Short: "Checks that a github / gitlab / gitea project has opened merge requests",
-	Long:  ``,
+	Long: `Checks that a github / gitlab / gitea project has opened merge requests
+
+` + nagios.FilterFieldsHelp() + "\n" + nagios.MetricsHelp(),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		nagios.ProbeCobraAdapter(cmd, args, nagiosConfigViperAdapter())
+		runCheck(cmd, args, "")
 	},
 	SilenceUsage:  true,
 	SilenceErrors: true,
@@ -85,9 +164,19 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(ctx context.Context) {
-	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		panic(err)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	if err == nil {
+		return
+	}
+
+	// checks must always answer with a nagios status
+	if isCheckCommand(cmd) {
+		checker := ctx.Value(nagios.CheckerContextKey).(*nagios.Check)
+		checker.Unknownf("%s", err)
 	}
+
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	os.Exit(1)
 }
 
 func init() {
@@ -99,32 +188,35 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&cmdFlags.ConfigFile, "config", "c", "", "config file (default is /etc/nagios-plugin-git-hosted-project-merge-requests/config.yaml)")
 
-	rootCmd.Flags().StringVarP(&cmdFlags.Host, "host", "H", "", "host to check (API endpoint)")
-	rootCmd.MarkFlagRequired("host")
-
-	rootCmd.Flags().StringVarP(&cmdFlags.Project, "project", "P", "", "project to check for opened MergeRequests")
-	rootCmd.MarkFlagRequired("project")
-
-	rootCmd.Flags().StringVarP(&cmdFlags.GitProvider, "git-provider", "p", "", fmt.Sprintf("git provider can be one of %s", strings.Join([]string{nagios.GitlabGitProvider}, ",")))
+	rootCmd.PersistentFlags().StringVarP(&cmdFlags.Host, "host", "H", "", "host to check (API endpoint)")
+	rootCmd.PersistentFlags().StringVarP(&cmdFlags.GitProvider, "git-provider", "p", "", fmt.Sprintf("git provider can be one of %s", strings.Join(nagios.SupportedGitProviders, ",")))
+	rootCmd.PersistentFlags().StringVar(&cmdFlags.APIToken, "api-token", "", "API Token used for authentication")
 
 	rootCmd.PersistentFlags().DurationVarP(&cmdFlags.Timeout, "timeout", "t", 30*time.Second, "Global timeout")
+	rootCmd.PersistentFlags().StringVar(&cmdFlags.Locale, "locale", nagios.LocaleEnglish, fmt.Sprintf("language of the messages and of their durations, can be one of %s", strings.Join(nagios.Locales, ",")))
 	rootCmd.PersistentFlags().BoolVarP(&cmdFlags.Debug, "debug", "d", false, "Enable debug")
 
-	rootCmd.Flags().StringVar(&cmdFlags.APIToken, "api-token", "", "API Token used for authentication")
-	rootCmd.Flags().StringVar(&cmdFlags.TargetBranch, "target-branch", "master", "Only consider merge requests with this target-branch")
-
-	rootCmd.Flags().DurationVar(&cmdFlags.WarningLastUpdateDelay, "warning-last-update", 6*time.Hour, "warning if last-update was that delay ago")
-	rootCmd.Flags().DurationVar(&cmdFlags.CriticalLastUpdateDelay, "critical-last-update", 24*time.Hour, "critical if last-update was that delay ago")
-
-	viper.BindPFlag("host", rootCmd.Flags().Lookup("host"))
-	viper.BindPFlag("project", rootCmd.Flags().Lookup("project"))
-	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
-	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
-	viper.BindPFlag("api-token", rootCmd.Flags().Lookup("api-token"))
-	viper.BindPFlag("git-provider", rootCmd.Flags().Lookup("git-provider"))
-	viper.BindPFlag("target-branch", rootCmd.Flags().Lookup("target-branch"))
-	viper.BindPFlag("warning-last-update", rootCmd.Flags().Lookup("warning-last-update"))
-	viper.BindPFlag("critical-last-update", rootCmd.Flags().Lookup("critical-last-update"))
+	// invoking the root command without subcommand runs any check mode,
+	// so that existing nagios command definitions keep working
+	rootCmd.Flags().StringVarP(&cmdFlags.Mode, "mode", "m", nagios.MergeRequestsMode, fmt.Sprintf("check mode can be one of %s", strings.Join(nagios.SupportedModes, ",")))
+	addSelectionFlags(rootCmd.Flags())
+	addMultiProjectFlags(rootCmd.Flags())
+	addLastUpdateFlags(rootCmd.Flags())
+	addMergeRequestsFlags(rootCmd.Flags())
+	addMessageTemplateFlags(rootCmd.Flags())
+	addCountFlags(rootCmd.Flags())
+	addPerUserFlags(rootCmd.Flags())
+	addLookbackFlag(rootCmd.Flags())
+	addAbandonedFlags(rootCmd.Flags())
+	addBranchesFlags(rootCmd.Flags())
+	addIssuesFlags(rootCmd.Flags())
+	addMetricFlags(rootCmd.Flags())
+	addOutputFlags(rootCmd.Flags())
+	addSinkFlags(rootCmd.Flags())
+	addPassiveTemplateFlags(rootCmd.Flags())
+	addNRDPFlags(rootCmd.Flags())
+	addCheckmkFlags(rootCmd.Flags())
+	addNotifyFlags(rootCmd.Flags())
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -153,16 +245,114 @@ func initConfig() {
 	}
 }
 
-func nagiosConfigViperAdapter() nagios.ProbeConfig {
-	return nagios.ProbeConfig{
+func nagiosConfigViperAdapter() (nagios.ProbeConfig, error) {
+	cfg := nagios.ProbeConfig{
 		Timeout:                 viper.GetDuration("timeout"),
+		Locale:                  viper.GetString("locale"),
 		APIEndpoint:             viper.GetString("host"),
 		Project:                 viper.GetString("project"),
+		Projects:                viper.GetStringSlice("projects"),
+		Group:                   viper.GetString("group"),
 		Debug:                   viper.GetBool("debug"),
 		APIToken:                viper.GetString("api-token"),
 		GitProvider:             viper.GetString("git-provider"),
 		TargetBranch:            viper.GetString("target-branch"),
 		WarningLastUpdateDelay:  viper.GetDuration("warning-last-update"),
 		CriticalLastUpdateDelay: viper.GetDuration("critical-last-update"),
+		CheckConflicts:          viper.GetBool("check-conflicts"),
+		ConflictsSeverity:       viper.GetString("conflicts-severity"),
+		CheckFailedPipelines:    viper.GetBool("check-failed-pipelines"),
+		FailedPipelinesSeverity: viper.GetString("failed-pipelines-severity"),
+		Filter:                  viper.GetString("filter"),
+		OKSummaryTemplate:       viper.GetString("ok-summary-template"),
+		ProblemTemplate:         viper.GetString("problem-template"),
+		LongOutputTemplate:      viper.GetString("long-output-template"),
+		Mode:                    viper.GetString("mode"),
+		WarningOpened:           viper.GetString("warning-opened"),
+		CriticalOpened:          viper.GetString("critical-opened"),
+		OlderThan:               viper.GetDuration("older-than"),
+		WarningOlder:            viper.GetString("warning-older"),
+		CriticalOlder:           viper.GetString("critical-older"),
+		PerUser:                 viper.GetString("per-user"),
+		WarningPerUser:          viper.GetString("warning-per-user"),
+		CriticalPerUser:         viper.GetString("critical-per-user"),
+		WarningMetric:           viper.GetStringMapString("warning-metric"),
+		CriticalMetric:          viper.GetStringMapString("critical-metric"),
+		CheckMetrics:            viper.GetStringSlice("check-metric"),
+		Lookback:                viper.GetDuration("lookback"),
+		BotPattern:              viper.GetString("bot-pattern"),
+		StaleLabels:             viper.GetStringSlice("stale-labels"),
+		ListBotClosed:           viper.GetBool("list-bot-closed"),
+		ExcludeBranches:         viper.GetStringSlice("exclude-branches"),
+		IssueLabels:             viper.GetStringSlice("issue-labels"),
+		IssueMilestone:          viper.GetString("issue-milestone"),
+		IssueAssignee:           viper.GetString("issue-assignee"),
+		Output:                  viper.GetString("output"),
+		ListenAddress:           viper.GetString("listen-address"),
+		RefreshInterval:         viper.GetDuration("refresh-interval"),
+		GraphiteAddress:         viper.GetString("graphite-address"),
+		GraphitePrefix:          viper.GetString("graphite-prefix"),
+		InfluxDBURL:             viper.GetString("influxdb-url"),
+		InfluxDBToken:           viper.GetString("influxdb-token"),
+		TextfilePath:            viper.GetString("textfile-path"),
+		PassiveHostTemplate:     viper.GetString("passive-host-template"),
+		PassiveServiceTemplate:  viper.GetString("passive-service-template"),
+		Icinga2URL:              viper.GetString("icinga2-url"),
+		Icinga2User:             viper.GetString("icinga2-user"),
+		Icinga2Password:         viper.GetString("icinga2-password"),
+		Icinga2CAFile:           viper.GetString("icinga2-ca-file"),
+		CommandFile:             viper.GetString("command-file"),
+		NRDPURL:                 viper.GetString("nrdp-url"),
+		NRDPToken:               viper.GetString("nrdp-token"),
+		NRDPFormat:              viper.GetString("nrdp-format"),
+		NRDPRetries:             viper.GetInt("nrdp-retries"),
+		NRDPDryRun:              viper.GetBool("nrdp-dry-run"),
+		CheckmkServiceTemplate:  viper.GetString("checkmk-service-template"),
+		NotifyWebhookURL:        viper.GetString("notify-webhook-url"),
+		NotifyFormat:            viper.GetString("notify-format"),
+		NotifyStateFile:         viper.GetString("notify-state-file"),
+		NotifyInterval:          viper.GetDuration("notify-interval"),
+		NudgeTemplate:           viper.GetString("nudge-template"),
+		NudgeLabel:              viper.GetString("nudge-label"),
+		NudgeCooldown:           viper.GetDuration("nudge-cooldown"),
+		DryRun:                  viper.GetBool("dry-run"),
+		SMTPAddress:             viper.GetString("smtp-address"),
+		SMTPUser:                viper.GetString("smtp-user"),
+		SMTPPassword:            viper.GetString("smtp-password"),
+		SMTPStartTLS:            viper.GetBool("smtp-starttls"),
+		SMTPFrom:                viper.GetString("smtp-from"),
+		ReportGroupBy:           viper.GetString("report-group-by"),
+		ReportRecipients:        viper.GetStringMapString("report-recipients"),
+		ReportEmailDomain:       viper.GetString("report-email-domain"),
+		ReportAllowedDomains:    viper.GetStringSlice("report-allowed-domains"),
+		ReportSubjectTemplate:   viper.GetString("report-subject-template"),
+		ReportTextTemplate:      viper.GetString("report-text-template"),
+		ReportHTMLTemplate:      viper.GetString("report-html-template"),
+		ReportToStdout:          viper.GetBool("to-stdout"),
+	}
+
+	// rules can only be defined in the configuration file
+	if err := viper.UnmarshalKey("rules", &cfg.Rules); err != nil {
+		return cfg, errors.Wrap(err, "decoding rules")
+	}
+	if err := viper.UnmarshalKey("notify-channels", &cfg.NotifyChannels); err != nil {
+		return cfg, errors.Wrap(err, "decoding notify channels")
+	}
+
+	return cfg, nil
+}
+
+// withTimeout runs f, giving up after the configured timeout
+func withTimeout(cfg nagios.ProbeConfig, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(cfg.Timeout):
+		return fmt.Errorf("timeout after %s", cfg.Timeout)
 	}
 }
@@ -0,0 +1,87 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Expose merge requests metrics to Prometheus",
+	Long: `Expose merge requests metrics to Prometheus
+
+Metrics are served on /metrics, labelled by project and target branch,
+and refreshed every --refresh-interval using the same provider query,
+filter and rules as the checks. --timeout applies to each refresh.
+
+` + nagios.FilterFieldsHelp(),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		exporter, err := nagios.NewExporter(cfg)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return exporter.Serve(ctx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&cmdFlags.ListenAddress, "listen-address", ":9723", "address to serve the metrics on")
+	serveCmd.Flags().DurationVar(&cmdFlags.RefreshInterval, "refresh-interval", 5*time.Minute, "delay between two refreshes of the metrics")
+	addSelectionFlags(serveCmd.Flags())
+	addMultiProjectFlags(serveCmd.Flags())
+	addLastUpdateFlags(serveCmd.Flags())
+	addMergeRequestsFlags(serveCmd.Flags())
+	addLookbackFlag(serveCmd.Flags())
+}
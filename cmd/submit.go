@@ -0,0 +1,113 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+var submitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Submit one passive check result per project",
+	Long: `Submit one passive check result per project
+
+The check selected by --mode is run on every project of --group, --projects
+or --project, and its result is submitted to the Icinga2 API
+(process-check-result) and/or the Nagios external command file
+(PROCESS_SERVICE_CHECK_RESULT). --timeout applies to each project.
+
+The host and service names are Go templates executed with .Project
+(e.g. group/subgroup/project), .Name (project), .Namespace (group/subgroup)
+and .Mode.
+
+` + nagios.FilterFieldsHelp() + "\n" + nagios.MetricsHelp(),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		submissions, err := nagios.SubmitPassiveResults(cfg)
+		if err != nil {
+			return err
+		}
+
+		var failed int
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROJECT\tHOST\tSERVICE\tSTATUS\tSUBMISSION")
+		for _, s := range submissions {
+			submission := "ok"
+			if s.Err != nil {
+				failed++
+				submission = strings.ReplaceAll(s.Err.Error(), "\n", " ")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Project, s.Host, s.Service, s.Status, submission)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d submissions failed", failed, len(submissions))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(submitCmd)
+
+	submitCmd.Flags().StringVarP(&cmdFlags.Mode, "mode", "m", nagios.MergeRequestsMode, fmt.Sprintf("check mode can be one of %s", strings.Join(nagios.SupportedModes, ",")))
+	addSelectionFlags(submitCmd.Flags())
+	addMultiProjectFlags(submitCmd.Flags())
+	addLastUpdateFlags(submitCmd.Flags())
+	addMergeRequestsFlags(submitCmd.Flags())
+	addMessageTemplateFlags(submitCmd.Flags())
+	addCountFlags(submitCmd.Flags())
+	addPerUserFlags(submitCmd.Flags())
+	addLookbackFlag(submitCmd.Flags())
+	addAbandonedFlags(submitCmd.Flags())
+	addBranchesFlags(submitCmd.Flags())
+	addIssuesFlags(submitCmd.Flags())
+	addMetricFlags(submitCmd.Flags())
+	addPassiveFlags(submitCmd.Flags())
+}
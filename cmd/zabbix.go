@@ -0,0 +1,128 @@
+/*
+Copyright © 2021 Remi Ferrand
+
+Contributor(s): Remi Ferrand <riton.github_at_gmail(dot)com>, 2021
+
+This software is a computer program whose purpose is to [describe
+functionalities and technical features of your software].
+
+This software is governed by the CeCILL-B license under French law and
+abiding by the rules of distribution of free software.  You can  use,
+modify and/ or redistribute the software under the terms of the CeCILL-B
+license as circulated by CEA, CNRS and INRIA at the following URL
+"http://www.cecill.info".
+
+As a counterpart to the access to the source code and  rights to copy,
+modify and redistribute granted by the license, users are provided only
+with a limited warranty  and the software's author,  the holder of the
+economic rights,  and the successive licensors  have only  limited
+liability.
+
+In this respect, the user's attention is drawn to the risks associated
+with loading,  using,  modifying and/or developing or reproducing the
+software by the user in light of its specific status of free software,
+that may mean  that it is complicated to manipulate,  and  that  also
+therefore means  that it is reserved for developers  and  experienced
+professionals having in-depth computer knowledge. Users are therefore
+encouraged to load and test the software's suitability as regards their
+requirements in conditions enabling the security of their systems and/or
+data to be ensured and,  more generally, to use and operate it in the
+same conditions as regards security.
+
+The fact that you are presently reading this means that you have had
+knowledge of the CeCILL-B license and that you accept its terms.
+
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
+	"github.com/spf13/cobra"
+)
+
+// zabbixCmd groups the Zabbix agent integration commands
+var zabbixCmd = &cobra.Command{
+	Use:   "zabbix",
+	Short: "Integrate with Zabbix low-level discovery and user parameters",
+}
+
+var zabbixDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Print the projects and target branches as a low-level discovery document",
+	Long: `Print the projects and target branches as a low-level discovery document
+
+Every project of --group, --projects or --project is discovered with the
+{#PROJECT}, {#PROJECT_NAME}, {#NAMESPACE} and {#TARGET_BRANCH} macros.
+Without --target-branch, every project is discovered with an empty
+{#TARGET_BRANCH}, standing for all the branches, followed by the branches
+targeted by its opened merge requests. A project without opened merge
+requests is thus still discovered, and Zabbix does not remove its items.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		var discovery nagios.ZabbixDiscovery
+		if err := withTimeout(cfg, func() error {
+			discovery, err = nagios.DiscoverZabbix(cfg)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return json.NewEncoder(cmd.OutOrStdout()).Encode(discovery)
+	},
+}
+
+var zabbixGetCmd = &cobra.Command{
+	Use:   "get METRIC",
+	Short: "Print the value of a metric of a project",
+	Long: `Print the value of a metric of a project
+
+The metric is computed over the merge requests (or branches, or issues)
+of --project the same way the checks do. Durations are in seconds.
+The command fails when the metric cannot be computed, such as the age of
+the oldest merge request of a project without merge requests.
+
+` + nagios.MetricsHelp(),
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := nagiosConfigViperAdapter()
+		if err != nil {
+			return err
+		}
+
+		var value float64
+		if err := withTimeout(cfg, func() error {
+			value, err = nagios.MetricValue(cfg, args[0])
+			return err
+		}); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), strconv.FormatFloat(value, 'f', -1, 64))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(zabbixCmd)
+	zabbixCmd.AddCommand(zabbixDiscoverCmd, zabbixGetCmd)
+
+	addSelectionFlags(zabbixDiscoverCmd.Flags())
+	addMultiProjectFlags(zabbixDiscoverCmd.Flags())
+
+	addSelectionFlags(zabbixGetCmd.Flags())
+	addLastUpdateFlags(zabbixGetCmd.Flags())
+	addCountFlags(zabbixGetCmd.Flags())
+	addLookbackFlag(zabbixGetCmd.Flags())
+	addAbandonedFlags(zabbixGetCmd.Flags())
+	addBranchesFlags(zabbixGetCmd.Flags())
+	addIssuesFlags(zabbixGetCmd.Flags())
+}
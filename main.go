@@ -40,12 +40,20 @@ import (
 
 	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/cmd"
 	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/nagios"
-	"github.com/riton/nagiosplugin/v2"
+)
+
+// set by goreleaser
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
 )
 
 func main() {
-	checker := nagiosplugin.NewCheck()
-	defer checker.Finish()
+	cmd.SetVersion(version, commit, date)
+
+	// checks finish the nagios check themselves, other commands must not print any status
+	checker := nagios.NewCheck()
 	ctx := context.WithValue(context.Background(), nagios.CheckerContextKey, checker)
 	cmd.Execute(ctx)
 }
@@ -0,0 +1,98 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// closedSince returns the merge requests closed after since
+func closedSince(mr []MergeRequest, since time.Time) []MergeRequest {
+	var closed []MergeRequest
+	for _, m := range mr {
+		if m.ClosedAt.Before(since) {
+			continue
+		}
+		closed = append(closed, m)
+	}
+	return closed
+}
+
+// closedByBot tells whether m was closed by a bot or carries a label set by a stale rule
+func (c nagiosProbe) closedByBot(m MergeRequest) bool {
+	if m.ClosedBy != "" && c.botPattern.MatchString(m.ClosedBy) {
+		return true
+	}
+	for _, l := range c.cfg.StaleLabels {
+		if m.HasLabel(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAbandoned reports the merge requests closed without being merged
+// during the lookback window, compared to the merged ones
+func (c nagiosProbe) checkAbandoned(mrChecker GitMergeRequestChecker) {
+	since := time.Now().Add(-c.cfg.Lookback)
+
+	closed, _, err := c.listMergeRequests(mrChecker, ListMergeRequestsOptions{
+		State:        MergeRequestStateClosed,
+		TargetBranch: c.cfg.TargetBranch,
+		UpdatedAfter: since,
+	}, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+	merged, _, err := c.listMergeRequests(mrChecker, ListMergeRequestsOptions{
+		State:        MergeRequestStateMerged,
+		TargetBranch: c.cfg.TargetBranch,
+		UpdatedAfter: since,
+	}, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+	c.addTotalDurationPerfDatum()
+
+	in := metricInput{
+		closed: closedSince(closed, since),
+		merged: mergedSince(merged, since),
+	}
+	for _, m := range in.closed {
+		if c.closedByBot(m) {
+			log.WithFields(log.Fields{
+				"merge-request": m.IID,
+				"closed-by":     m.ClosedBy,
+				"labels":        m.Labels,
+			}).Debug("merge request closed by a bot or a stale rule")
+			in.botClosed = append(in.botClosed, m)
+		}
+	}
+
+	defaultMetrics := []string{ClosedMergeRequestsMetric, MergedMergeRequestsMetric, ClosedMergedRatioMetric}
+	if c.cfg.ListBotClosed {
+		defaultMetrics = append(defaultMetrics, BotClosedMergeRequestsMetric)
+
+		var longOutput []string
+		for _, m := range in.botClosed {
+			closedBy := m.ClosedBy
+			if closedBy == "" {
+				closedBy = localize(c.cfg.Locale, "a stale rule")
+			}
+			longOutput = append(longOutput, fmt.Sprintf(localize(c.cfg.Locale, "Merge request %d closed by %s: %s"), m.IID, closedBy, m.Title))
+		}
+		if len(longOutput) > 0 {
+			c.nagCheck.AddLongPluginOutput(strings.Join(longOutput, "\n"))
+		}
+	}
+
+	c.checkMetrics(in, defaultMetrics...)
+
+	c.nagCheck.AddResultf(nagiosplugin.OK, localize(c.cfg.Locale, "%d merge requests closed without merge and %d merged in the last %s"),
+		len(in.closed), len(in.merged), HumanizeDuration(c.cfg.Lookback, c.cfg.Locale))
+}
@@ -0,0 +1,12 @@
+package nagios
+
+import "time"
+
+type Branch struct {
+	Name         string
+	Project      string
+	WebURL       string
+	Protected    bool
+	Default      bool
+	LastCommitAt time.Time
+}
@@ -0,0 +1,104 @@
+package nagios
+
+import (
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// isExcludedBranch returns true if the branch must not be checked:
+// the default branch, protected branches and the ones matching an excluded pattern
+func (c nagiosProbe) isExcludedBranch(b Branch) bool {
+	if b.Default || b.Protected {
+		return true
+	}
+	for _, pattern := range c.cfg.ExcludeBranches {
+		if ok, _ := path.Match(pattern, b.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchBranches returns the branches of the project that are not excluded
+// and are not the source branch of an opened merge request.
+// Branches whose last commit date is unknown cannot be evaluated and are skipped.
+func (c nagiosProbe) fetchBranches(mrChecker GitMergeRequestChecker, project string) ([]Branch, error) {
+	branches, err := mrChecker.ListBranches(project)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"project":      project,
+			"api-endpoint": c.cfg.APIEndpoint,
+		}).Error("fail to list branches")
+		return nil, errors.Wrapf(err, "fail to list branches of %s", project)
+	}
+
+	mr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+		State: MergeRequestStateOpened,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to check for merge requests of %s", project)
+	}
+
+	withMergeRequest := make(map[string]bool)
+	for _, m := range mr {
+		withMergeRequest[m.SourceBranch] = true
+	}
+
+	var candidates []Branch
+	for _, b := range branches {
+		if c.isExcludedBranch(b) {
+			log.WithField("branch", b.Name).Debug("branch excluded")
+			continue
+		}
+		if withMergeRequest[b.Name] {
+			log.WithField("branch", b.Name).Debug("branch has an opened merge request")
+			continue
+		}
+		if b.LastCommitAt.IsZero() {
+			log.WithField("branch", b.Name).Debug("branch last commit date is unknown, skipping")
+			continue
+		}
+		candidates = append(candidates, b)
+	}
+
+	return candidates, nil
+}
+
+// checkBranches alerts on the branches without commit for too long
+// and that never made it to a merge request
+func (c nagiosProbe) checkBranches(mrChecker GitMergeRequestChecker) {
+	branches, err := c.fetchBranches(mrChecker, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+	c.addTotalDurationPerfDatum()
+
+	c.checkMetrics(metricInput{branches: branches},
+		BranchesWithoutMergeRequestMetric,
+		StaleBranchesMetric,
+		OldestBranchMetric,
+	)
+
+	// the service state only comes from the metrics selected with --check-metric
+	if len(c.checkedMetrics) > 0 {
+		c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "All checked metrics within thresholds"))
+		return
+	}
+
+	c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "No stale branches"))
+
+	for _, b := range branches {
+		tSinceLastCommit := time.Since(b.LastCommitAt)
+		if tSinceLastCommit >= c.cfg.CriticalLastUpdateDelay {
+			c.nagCheck.AddResultf(nagiosplugin.CRITICAL, localize(c.cfg.Locale, "Branch %s last commit was %s ago"), b.Name, HumanizeDuration(tSinceLastCommit, c.cfg.Locale))
+		} else if tSinceLastCommit >= c.cfg.WarningLastUpdateDelay {
+			c.nagCheck.AddResultf(nagiosplugin.WARNING, localize(c.cfg.Locale, "Branch %s last commit was %s ago"), b.Name, HumanizeDuration(tSinceLastCommit, c.cfg.Locale))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package nagios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchBranches(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v4/":
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+		case "/api/v4/projects/group/project/repository/branches":
+			commit := func(id string) string {
+				return `"commit":{"id":"` + id + `","committed_date":"2021-06-01T12:00:00Z"}`
+			}
+			w.Write([]byte(`[{"name":"main","default":true,` + commit("a1") + `},` +
+				`{"name":"release/1.0",` + commit("a2") + `},` +
+				`{"name":"fix",` + commit("a3") + `},` +
+				`{"name":"old-experiment",` + commit("a4") + `},` +
+				`{"name":"no-date","commit":{"id":"a5"}},` +
+				`{"name":"no-commit"}]`))
+		case "/api/v4/projects/group/project/merge_requests":
+			w.Write([]byte(`[{"id":1,"iid":1,"source_branch":"fix","target_branch":"main",` +
+				`"created_at":"2021-06-01T12:00:00Z","updated_at":"2021-06-01T12:00:00Z"}]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := ProbeConfig{
+		APIEndpoint:     srv.URL,
+		GitProvider:     GitlabGitProvider,
+		Project:         "group/project",
+		ExcludeBranches: []string{"release/*"},
+		Timeout:         5 * time.Second,
+	}
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err := nagiosProbe{cfg: cfg}.fetchBranches(mrChecker, cfg.Project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	// neither the default, excluded and merge requests source branches,
+	// nor the ones without commit date
+	if want := []string{"old-experiment"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("branches = %v, want %v", names, want)
+	}
+}
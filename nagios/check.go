@@ -0,0 +1,395 @@
+package nagios
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/report"
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OutputNagios is the classic nagios plugin output
+	OutputNagios = "nagios"
+	// OutputJSON is a report.Report
+	OutputJSON = "json"
+	// OutputNRDP submits the check result to NRDP instead of printing it
+	OutputNRDP = "nrdp"
+	// OutputCheckmk is a Checkmk local check line
+	OutputCheckmk = "checkmk"
+)
+
+// SupportedOutputs lists the available check output formats
+var SupportedOutputs = []string{OutputNagios, OutputJSON, OutputNRDP, OutputCheckmk}
+
+// Check wraps a nagiosplugin.Check and records everything reported
+// to it, so that the outcome of the check can be rendered in other formats
+type Check struct {
+	plugin *nagiosplugin.Check
+	output string
+	// captured checks are not rendered, Finish only marks them done
+	// and whatever is reported afterwards is ignored
+	captured bool
+	done     bool
+
+	status        nagiosplugin.Status
+	results       []report.Result
+	perfdata      []report.PerfDatum
+	rendered      []string // perfdata in the nagios format
+	longOutput    string
+	mergeRequests []report.MergeRequest
+
+	// deadline of the sinks
+	deadline time.Time
+
+	sinks       []perfdataSink
+	sinkProject string
+	sinkMode    string
+
+	// names of the monitored object with the passive and checkmk outputs
+	host    string
+	service string
+
+	// OutputNRDP
+	submitter     passiveSubmitter
+	submitTimeout time.Duration
+
+	notifier *webhookNotifier
+}
+
+// NewCheck returns an empty Check rendering the classic nagios plugin output
+func NewCheck() *Check {
+	return &Check{
+		plugin: nagiosplugin.NewCheck(),
+		output: OutputNagios,
+	}
+}
+
+// SetOutput selects the format the check is rendered in by Finish
+func (c *Check) SetOutput(output string) error {
+	for _, o := range SupportedOutputs {
+		if o == output {
+			c.output = output
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output %q (expected one of %s)", output, strings.Join(SupportedOutputs, ","))
+}
+
+// setDeadline sets the time Finish must be done by
+func (c *Check) setDeadline(deadline time.Time) {
+	c.deadline = deadline
+}
+
+// setSinks registers the sinks the perfdata are pushed to by Finish
+func (c *Check) setSinks(sinks []perfdataSink, project, mode string) {
+	c.sinks = sinks
+	c.sinkProject = project
+	c.sinkMode = mode
+}
+
+// setNames sets the host and service the result of the check is rendered for
+func (c *Check) setNames(host, service string) {
+	c.host = host
+	c.service = service
+}
+
+// setSubmitter registers how Finish submits the result with a passive output.
+// The submission has its own timeout so that the result of a check
+// that timed out is still submitted.
+func (c *Check) setSubmitter(submitter passiveSubmitter, timeout time.Duration) {
+	c.submitter = submitter
+	c.submitTimeout = timeout
+}
+
+// setNotifier registers the notifier Finish posts the offending merge requests with
+func (c *Check) setNotifier(notifier *webhookNotifier) {
+	c.notifier = notifier
+}
+
+// AddResult adds a check result, the worst status is the check status
+func (c *Check) AddResult(status nagiosplugin.Status, message string) {
+	if c.done {
+		return
+	}
+	c.plugin.AddResult(status, message)
+
+	c.results = append(c.results, report.Result{Status: status.String(), Message: message})
+	if status > c.status {
+		c.status = status
+	}
+}
+
+// AddResultf functions as AddResult, but takes a printf-style format
+func (c *Check) AddResultf(status nagiosplugin.Status, format string, v ...interface{}) {
+	c.AddResult(status, fmt.Sprintf(format, v...))
+}
+
+// AddLongPluginOutput appends s to the long output
+func (c *Check) AddLongPluginOutput(s string) {
+	if c.done {
+		return
+	}
+	c.plugin.AddLongPluginOutput(s)
+	c.longOutput += s
+}
+
+// AddPerfDatum adds a performance data
+func (c *Check) AddPerfDatum(label, unit string, value nagiosplugin.PerfDatumValue, warn, crit *nagiosplugin.Range, min, max *float64) error {
+	if c.done {
+		return nil
+	}
+	if err := c.plugin.AddPerfDatum(label, unit, value, warn, crit, min, max); err != nil {
+		return err
+	}
+	pd, _ := nagiosplugin.NewPerfDatum(label, unit, value, warn, crit, min, max)
+	c.rendered = append(c.rendered, pd.String())
+
+	datum := report.PerfDatum{
+		Label: label,
+		Unit:  unit,
+		Min:   min,
+		Max:   max,
+	}
+	if f, ok := value.(nagiosplugin.FloatPerfDatumValue); ok {
+		v := float64(f)
+		datum.Value = &v
+	}
+	if warn != nil {
+		datum.Warning = warn.String()
+	}
+	if crit != nil {
+		datum.Critical = crit.String()
+	}
+	c.perfdata = append(c.perfdata, datum)
+
+	return nil
+}
+
+// AddMergeRequest records an evaluated merge request and its individual status.
+// Merge requests are only part of the machine readable outputs.
+func (c *Check) AddMergeRequest(m MergeRequest, status nagiosplugin.Status, reasons ...string) {
+	if c.done {
+		return
+	}
+	labels := m.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	c.mergeRequests = append(c.mergeRequests, report.MergeRequest{
+		ID:           m.ID,
+		IID:          m.IID,
+		Project:      m.Project,
+		Title:        m.Title,
+		Author:       m.Author,
+		TargetBranch: m.TargetBranch,
+		WebURL:       m.WebURL,
+		Labels:       labels,
+		Draft:        m.Draft,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+		Status:       status.String(),
+		Reasons:      reasons,
+	})
+}
+
+// Report returns the outcome of the check
+func (c *Check) Report() report.Report {
+	var summary []string
+	for _, r := range c.results {
+		if r.Status == c.status.String() {
+			summary = append(summary, r.Message)
+		}
+	}
+
+	r := report.Report{
+		SchemaVersion: report.SchemaVersion,
+		Status:        c.status.String(),
+		ExitCode:      int(c.status),
+		Summary:       strings.Join(summary, ", "),
+		LongOutput:    c.longOutput,
+		Results:       c.results,
+		Perfdata:      c.perfdata,
+		MergeRequests: c.mergeRequests,
+	}
+	if r.Results == nil {
+		r.Results = []report.Result{}
+	}
+	if r.Perfdata == nil {
+		r.Perfdata = []report.PerfDatum{}
+	}
+	return r
+}
+
+// Finish ends the check, prints its output (to stdout), and exits with
+// the nagios status. A captured check is only marked done, its caller
+// must return.
+func (c *Check) Finish() {
+	if c.done {
+		return
+	}
+	if len(c.results) == 0 {
+		c.AddResult(nagiosplugin.UNKNOWN, "no check result specified")
+	}
+
+	if c.captured {
+		c.done = true
+		return
+	}
+
+	c.flushSinks()
+	c.notify()
+
+	// without submitter (invalid configuration) the check is rendered as usual
+	if c.output == OutputNRDP && c.submitter != nil {
+		c.submit()
+	}
+
+	// Checkmk agents expect local checks to always succeed
+	if c.output == OutputCheckmk {
+		fmt.Println(c.checkmkLine())
+		os.Exit(0)
+	}
+
+	if c.output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c.Report()); err != nil {
+			fmt.Printf("UNKNOWN: rendering json output: %s\n", err)
+			os.Exit(int(nagiosplugin.UNKNOWN))
+		}
+		os.Exit(int(c.status))
+	}
+
+	c.plugin.Finish()
+}
+
+// flushSinks pushes the perfdata to every sink until the sink deadline.
+// Sink failures are only logged, they never change the status of the check.
+func (c *Check) flushSinks() {
+	if len(c.sinks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), c.deadline)
+	defer cancel()
+
+	payload := sinkPayload{
+		Project:  c.sinkProject,
+		Mode:     c.sinkMode,
+		Status:   c.status,
+		Perfdata: c.perfdata,
+		Time:     time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range c.sinks {
+		wg.Add(1)
+		go func(s perfdataSink) {
+			defer wg.Done()
+			if err := s.Send(ctx, payload); err != nil {
+				log.WithFields(log.Fields{
+					"sink":  s.Name(),
+					"error": err,
+				}).Error("fail to send perfdata")
+				return
+			}
+			log.WithField("sink", s.Name()).Debug("perfdata sent successfully")
+		}(s)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.WithField("deadline", c.deadline).Error("perfdata sinks did not finish before the timeout")
+	}
+}
+
+// notify posts the digest of the offending merge requests until the sink deadline.
+// Like the sinks, notification failures never change the status of the check.
+func (c *Check) notify() {
+	if c.notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), c.deadline)
+	defer cancel()
+
+	if err := c.notifier.Notify(ctx, c.sinkProject, c.status, c.mergeRequests, time.Now()); err != nil {
+		log.WithField("error", err).Error("fail to notify")
+	}
+}
+
+// submit submits the result of the check and exits, with 1 if the submission failed.
+// The status of the check is only known to the monitoring.
+func (c *Check) submit() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.submitTimeout)
+	defer cancel()
+
+	err := c.submitter.Submit(ctx, passiveResult{
+		Host:     c.host,
+		Service:  c.service,
+		Status:   c.status,
+		Output:   c.pluginOutput(),
+		Perfdata: c.rendered,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: submitting %s result of %s!%s to %s: %s\n", c.status, c.host, c.service, c.submitter.Name(), err)
+		os.Exit(1)
+	}
+
+	log.WithFields(log.Fields{
+		"host":    c.host,
+		"service": c.service,
+		"status":  c.status,
+	}).Debug("check result submitted successfully")
+	os.Exit(0)
+}
+
+// evaluateCaptured runs eval on a captured check and returns the finished check
+func evaluateCaptured(eval func(c *Check)) *Check {
+	c := NewCheck()
+	c.captured = true
+
+	eval(c)
+	c.Finish()
+	return c
+}
+
+// pluginOutput returns the status line and the long output, without the perfdata
+func (c *Check) pluginOutput() string {
+	r := c.Report()
+	output := fmt.Sprintf("%s: %s", r.Status, r.Summary)
+	if r.LongOutput != "" {
+		output += "\n" + r.LongOutput
+	}
+	return output
+}
+
+// Exitf adds a result then immediately finishes the check
+func (c *Check) Exitf(status nagiosplugin.Status, format string, v ...interface{}) {
+	c.AddResultf(status, format, v...)
+	c.Finish()
+}
+
+// Criticalf exits the check with status CRITICAL and the message provided
+func (c *Check) Criticalf(format string, v ...interface{}) {
+	c.Exitf(nagiosplugin.CRITICAL, format, v...)
+}
+
+// Unknownf exits the check with status UNKNOWN and the message provided
+func (c *Check) Unknownf(format string, v ...interface{}) {
+	c.Exitf(nagiosplugin.UNKNOWN, format, v...)
+}
@@ -0,0 +1,143 @@
+package nagios
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/report"
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+var checkmkUnsafeMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// checkmkLine renders c as a Checkmk local check line:
+// <status> "<service>" <metrics> <summary>
+func (c *Check) checkmkLine() string {
+	metrics := checkmkMetrics(c.perfdata)
+	if metrics == "" {
+		metrics = "-"
+	}
+
+	r := c.Report()
+	summary := r.Summary
+	if r.LongOutput != "" {
+		// Checkmk turns a literal \n of the summary into the long output
+		summary += `\n` + strings.ReplaceAll(r.LongOutput, "\n", `\n`)
+	}
+
+	return fmt.Sprintf("%d %q %s %s", c.status, c.service, metrics, summary)
+}
+
+// checkmkMetrics converts perfdata to the Checkmk name=value;warn;crit;min;max format
+func checkmkMetrics(perfdata []report.PerfDatum) string {
+	var metrics []string
+	for _, d := range perfdata {
+		if d.Value == nil {
+			continue
+		}
+		metric := fmt.Sprintf("%s=%s;%s;%s;%s;%s",
+			checkmkUnsafeMetricChars.ReplaceAllString(d.Label, "_"),
+			formatCheckmkFloat(*d.Value),
+			checkmkLevel(d.Warning),
+			checkmkLevel(d.Critical),
+			formatCheckmkOptionalFloat(d.Min),
+			formatCheckmkOptionalFloat(d.Max),
+		)
+		metrics = append(metrics, strings.TrimRight(metric, ";"))
+	}
+	return strings.Join(metrics, "|")
+}
+
+// checkmkLevel converts a nagios range to a Checkmk upper level.
+// Checkmk levels are upper levels only: ranges alerting below a positive
+// value or inside a range cannot be converted and are left empty.
+// The perfdata never being negative, 10, 0:10 and ~:10 are the same level.
+func checkmkLevel(nagiosRange string) string {
+	if nagiosRange == "" {
+		return ""
+	}
+	r, err := nagiosplugin.ParseRange(nagiosRange)
+	if err != nil || r.AlertOnInside || !(r.Start <= 0 || math.IsInf(r.Start, -1)) || math.IsInf(r.End, 1) {
+		return ""
+	}
+	return formatCheckmkFloat(r.End)
+}
+
+func formatCheckmkFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatCheckmkOptionalFloat(f *float64) string {
+	if f == nil || math.IsInf(*f, 0) {
+		return ""
+	}
+	return formatCheckmkFloat(*f)
+}
+
+// isCheckmkMultiProject tells whether the checkmk output has one line per project.
+// The reviewer-load mode already spans the projects and keeps a single line.
+func isCheckmkMultiProject(cfg ProbeConfig) bool {
+	return cfg.Output == OutputCheckmk && cfg.Mode != ReviewerLoadMode &&
+		cfg.Project == "" && (cfg.Group != "" || len(cfg.Projects) > 0)
+}
+
+// checkmkService returns the Checkmk service name of the check of project
+func checkmkService(cfg ProbeConfig, project string) (string, error) {
+	t, err := parseNameTemplate("checkmk service", cfg.CheckmkServiceTemplate, cfg.Mode)
+	if err != nil {
+		return "", err
+	}
+	return executePassiveTemplate(t, newPassiveTemplateData(project, cfg.Mode))
+}
+
+// printCheckmkProjects evaluates the check on every project of the configured
+// group or list of projects and prints one local check line per project.
+// The sinks and the notifier of checker get the result of every project.
+func printCheckmkProjects(w io.Writer, cfg ProbeConfig, checker *Check) {
+	if !isSupportedGitProvider(cfg.GitProvider) {
+		checker.Unknownf("git provider %s is not supported yet", cfg.GitProvider)
+	}
+	// every project is checked with the same configuration
+	probe := nagiosProbe{cfg: passiveProjectConfig(cfg, "validation")}
+	if err := probe.init(); err != nil {
+		checker.Unknownf("invalid configuration: %s", err)
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		checker.Unknownf("fail to initialize %s checker: %s", cfg.GitProvider, err)
+	}
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		checker.Unknownf("%s", err)
+	}
+
+	for _, project := range projects {
+		service, err := checkmkService(cfg, project)
+		if err != nil {
+			checker.Unknownf("invalid configuration: %s", err)
+		}
+
+		c := evaluateProject(passiveProjectConfig(cfg, project))
+		c.service = service
+		log.WithFields(log.Fields{
+			"project": project,
+			"service": service,
+			"status":  c.status,
+		}).Debug("project evaluated")
+
+		c.setSinks(checker.sinks, project, cfg.Mode)
+		c.setNotifier(checker.notifier)
+		c.setDeadline(time.Now().Add(cfg.Timeout))
+		c.flushSinks()
+		c.notify()
+
+		fmt.Fprintln(w, c.checkmkLine())
+	}
+}
@@ -0,0 +1,121 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riton/nagiosplugin/v2"
+)
+
+func TestCheckmkLevel(t *testing.T) {
+	tests := []struct {
+		nagiosRange, want string
+	}{
+		{"", ""},
+		{"10", "10"},
+		{"0:10", "10"},
+		{"~:10", "10"},
+		{"2.5", "2.5"},
+		// alerting below a value or inside a range
+		{"10:", ""},
+		{"5:10", ""},
+		{"@10:20", ""},
+		{"@~:10", ""},
+		{"invalid", ""},
+	}
+
+	for _, tt := range tests {
+		if got := checkmkLevel(tt.nagiosRange); got != tt.want {
+			t.Errorf("checkmkLevel(%q) = %q, want %q", tt.nagiosRange, got, tt.want)
+		}
+	}
+}
+
+// recordingSink records the payloads sent to it
+type recordingSink struct {
+	mu       sync.Mutex
+	payloads []sinkPayload
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(ctx context.Context, p sinkPayload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payloads = append(s.payloads, p)
+	return nil
+}
+
+func TestPrintCheckmkProjects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v4/":
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+		case "/api/v4/projects/group/a/merge_requests":
+			w.Write([]byte(`[{"id":1,"iid":1,"title":"Fix the build","target_branch":"main","web_url":"https://gitlab.example.com/group/a/-/merge_requests/1",` +
+				`"created_at":"2021-06-01T12:00:00Z","updated_at":"2021-06-01T12:00:00Z"}]`))
+		case "/api/v4/projects/group/b/merge_requests":
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	webhook := newWebhookStandIn(t)
+
+	cfg := ProbeConfig{
+		APIEndpoint:             srv.URL,
+		GitProvider:             GitlabGitProvider,
+		Projects:                []string{"group/a", "group/b"},
+		TargetBranch:            "main",
+		Mode:                    MergeRequestsMode,
+		Output:                  OutputCheckmk,
+		CheckmkServiceTemplate:  "git {{ .Mode }} {{ .Project }}",
+		Timeout:                 5 * time.Second,
+		WarningLastUpdateDelay:  6 * time.Hour,
+		CriticalLastUpdateDelay: 24 * time.Hour,
+		NotifyWebhookURL:        webhook.URL,
+		NotifyFormat:            NotifyFormatMarkdown,
+		NotifyStateFile:         filepath.Join(t.TempDir(), "notify.json"),
+		NotifyInterval:          time.Hour,
+		Locale:                  LocaleEnglish,
+	}
+	notifier, err := newWebhookNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{}
+	checker := NewCheck()
+	checker.setSinks([]perfdataSink{sink}, sinkProject(cfg), cfg.Mode)
+	checker.setNotifier(notifier)
+
+	var out bytes.Buffer
+	printCheckmkProjects(&out, cfg, checker)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `2 "git merge-requests group/a" `) || !strings.HasPrefix(lines[1], `0 "git merge-requests group/b" `) {
+		t.Errorf("output =\n%s", out.String())
+	}
+
+	// every project is sent on its own
+	if len(sink.payloads) != 2 || sink.payloads[0].Project != "group/a" || sink.payloads[1].Project != "group/b" {
+		t.Errorf("sink payloads = %+v", sink.payloads)
+	}
+	if len(sink.payloads) == 2 && (sink.payloads[0].Status != nagiosplugin.CRITICAL || len(sink.payloads[0].Perfdata) == 0) {
+		t.Errorf("sink payload of group/a = %+v", sink.payloads[0])
+	}
+	digests := webhook.posted()
+	if len(digests) != 1 || !strings.Contains(digests[0], "1 merge requests of group/a need attention") {
+		t.Errorf("posted %q, want the digest of group/a", digests)
+	}
+}
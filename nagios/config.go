@@ -1,20 +1,155 @@
 package nagios
 
-import "time"
+import (
+	"context"
+	"net/http"
+	"time"
+)
 
 const (
 	GitlabGitProvider = "gitlab"
 	GithubGitProvider = "github"
 )
 
+const (
+	// MergeRequestsMode alerts on merge requests without activity for too long
+	MergeRequestsMode = "merge-requests"
+	// CountMode alerts on the number of opened merge requests
+	CountMode = "count"
+	// ReviewerLoadMode alerts on users holding too many merge requests across projects
+	ReviewerLoadMode = "reviewer-load"
+	// ThroughputMode reports the lead times of the recently merged merge requests
+	ThroughputMode = "throughput"
+	// AbandonedMode reports the merge requests recently closed without being merged
+	AbandonedMode = "abandoned"
+	// BranchesMode alerts on branches without commits for too long and without merge request
+	BranchesMode = "branches"
+	// IssuesMode alerts on issues without activity for too long
+	IssuesMode = "issues"
+)
+
+// SupportedModes lists the available check modes
+var SupportedModes = []string{MergeRequestsMode, CountMode, ReviewerLoadMode, ThroughputMode, AbandonedMode, BranchesMode, IssuesMode}
+
+const (
+	AssigneeRole = "assignee"
+	ReviewerRole = "reviewer"
+)
+
+// SupportedGitProviders lists the git providers the probe knows how to talk to
+var SupportedGitProviders = []string{GitlabGitProvider, GithubGitProvider}
+
 type ProbeConfig struct {
-	APIEndpoint             string        `mapstructure:"api-endpoint"`
-	Debug                   bool          `mapstructure:"debug"`
-	GitProvider             string        `mapstructure:"git-provider"`
-	APIToken                string        `mapstructure:"api-token"`
-	Project                 string        `mapstructure:"project"`
-	Timeout                 time.Duration `mapstructure:"timeout"`
-	TargetBranch            string        `mapstructure:"target-branch"`
-	WarningLastUpdateDelay  time.Duration `mapstructure:"delay-warning-last-update"`
-	CriticalLastUpdateDelay time.Duration `mapstructure:"delay-critical-last-update"`
+	APIEndpoint             string             `mapstructure:"api-endpoint"`
+	Debug                   bool               `mapstructure:"debug"`
+	GitProvider             string             `mapstructure:"git-provider"`
+	APIToken                string             `mapstructure:"api-token"`
+	Project                 string             `mapstructure:"project"`
+	Projects                []string           `mapstructure:"projects"`
+	Group                   string             `mapstructure:"group"`
+	Timeout                 time.Duration      `mapstructure:"timeout"`
+	Locale                  string             `mapstructure:"locale"`
+	TargetBranch            string             `mapstructure:"target-branch"`
+	WarningLastUpdateDelay  time.Duration      `mapstructure:"delay-warning-last-update"`
+	CriticalLastUpdateDelay time.Duration      `mapstructure:"delay-critical-last-update"`
+	CheckConflicts          bool               `mapstructure:"check-conflicts"`
+	ConflictsSeverity       string             `mapstructure:"conflicts-severity"`
+	CheckFailedPipelines    bool               `mapstructure:"check-failed-pipelines"`
+	FailedPipelinesSeverity string             `mapstructure:"failed-pipelines-severity"`
+	Filter                  string             `mapstructure:"filter"`
+	Rules                   []MergeRequestRule `mapstructure:"rules"`
+	OKSummaryTemplate       string             `mapstructure:"ok-summary-template"`
+	ProblemTemplate         string             `mapstructure:"problem-template"`
+	LongOutputTemplate      string             `mapstructure:"long-output-template"`
+	Mode                    string             `mapstructure:"mode"`
+	WarningOpened           string             `mapstructure:"warning-opened"`
+	CriticalOpened          string             `mapstructure:"critical-opened"`
+	OlderThan               time.Duration      `mapstructure:"older-than"`
+	WarningOlder            string             `mapstructure:"warning-older"`
+	CriticalOlder           string             `mapstructure:"critical-older"`
+	PerUser                 string             `mapstructure:"per-user"`
+	WarningPerUser          string             `mapstructure:"warning-per-user"`
+	CriticalPerUser         string             `mapstructure:"critical-per-user"`
+	WarningMetric           map[string]string  `mapstructure:"warning-metric"`
+	CriticalMetric          map[string]string  `mapstructure:"critical-metric"`
+	CheckMetrics            []string           `mapstructure:"check-metric"`
+	Lookback                time.Duration      `mapstructure:"lookback"`
+	BotPattern              string             `mapstructure:"bot-pattern"`
+	StaleLabels             []string           `mapstructure:"stale-labels"`
+	ListBotClosed           bool               `mapstructure:"list-bot-closed"`
+	ExcludeBranches         []string           `mapstructure:"exclude-branches"`
+	IssueLabels             []string           `mapstructure:"issue-labels"`
+	IssueMilestone          string             `mapstructure:"issue-milestone"`
+	IssueAssignee           string             `mapstructure:"issue-assignee"`
+	Output                  string             `mapstructure:"output"`
+	ListenAddress           string             `mapstructure:"listen-address"`
+	RefreshInterval         time.Duration      `mapstructure:"refresh-interval"`
+	GraphiteAddress         string             `mapstructure:"graphite-address"`
+	GraphitePrefix          string             `mapstructure:"graphite-prefix"`
+	InfluxDBURL             string             `mapstructure:"influxdb-url"`
+	InfluxDBToken           string             `mapstructure:"influxdb-token"`
+	TextfilePath            string             `mapstructure:"textfile-path"`
+	PassiveHostTemplate     string             `mapstructure:"passive-host-template"`
+	PassiveServiceTemplate  string             `mapstructure:"passive-service-template"`
+	Icinga2URL              string             `mapstructure:"icinga2-url"`
+	Icinga2User             string             `mapstructure:"icinga2-user"`
+	Icinga2Password         string             `mapstructure:"icinga2-password"`
+	Icinga2CAFile           string             `mapstructure:"icinga2-ca-file"`
+	CommandFile             string             `mapstructure:"command-file"`
+	NRDPURL                 string             `mapstructure:"nrdp-url"`
+	NRDPToken               string             `mapstructure:"nrdp-token"`
+	NRDPFormat              string             `mapstructure:"nrdp-format"`
+	NRDPRetries             int                `mapstructure:"nrdp-retries"`
+	NRDPDryRun              bool               `mapstructure:"nrdp-dry-run"`
+	CheckmkServiceTemplate  string             `mapstructure:"checkmk-service-template"`
+	NotifyWebhookURL        string             `mapstructure:"notify-webhook-url"`
+	NotifyChannels          []NotifyChannel    `mapstructure:"notify-channels"`
+	NotifyFormat            string             `mapstructure:"notify-format"`
+	NotifyStateFile         string             `mapstructure:"notify-state-file"`
+	NotifyInterval          time.Duration      `mapstructure:"notify-interval"`
+	NudgeTemplate           string             `mapstructure:"nudge-template"`
+	NudgeLabel              string             `mapstructure:"nudge-label"`
+	NudgeCooldown           time.Duration      `mapstructure:"nudge-cooldown"`
+	DryRun                  bool               `mapstructure:"dry-run"`
+	SMTPAddress             string             `mapstructure:"smtp-address"`
+	SMTPUser                string             `mapstructure:"smtp-user"`
+	SMTPPassword            string             `mapstructure:"smtp-password"`
+	SMTPStartTLS            bool               `mapstructure:"smtp-starttls"`
+	SMTPFrom                string             `mapstructure:"smtp-from"`
+	ReportGroupBy           string             `mapstructure:"report-group-by"`
+	ReportRecipients        map[string]string  `mapstructure:"report-recipients"`
+	ReportEmailDomain       string             `mapstructure:"report-email-domain"`
+	ReportAllowedDomains    []string           `mapstructure:"report-allowed-domains"`
+	ReportSubjectTemplate   string             `mapstructure:"report-subject-template"`
+	ReportTextTemplate      string             `mapstructure:"report-text-template"`
+	ReportHTMLTemplate      string             `mapstructure:"report-html-template"`
+	ReportToStdout          bool               `mapstructure:"to-stdout"`
+
+	// httpClient queries the git provider, http.DefaultClient if nil
+	httpClient *http.Client
+}
+
+// providerHTTPClient returns the client the git provider is queried with
+func (cfg ProbeConfig) providerHTTPClient() *http.Client {
+	if cfg.httpClient == nil {
+		return http.DefaultClient
+	}
+	return cfg.httpClient
+}
+
+// withContext returns a copy of cfg whose git provider requests fail once ctx is done
+func (cfg ProbeConfig) withContext(ctx context.Context) ProbeConfig {
+	cfg.httpClient = &http.Client{Transport: contextTransport{ctx: ctx, next: http.DefaultTransport}}
+	return cfg
+}
+
+// contextTransport binds the requests to ctx. The provider clients never
+// bind the requests to a context of their own.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
 }
@@ -0,0 +1,108 @@
+package nagios
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+func (c *nagiosProbe) initCountMode() error {
+	var err error
+
+	switch c.cfg.PerUser {
+	case "", AssigneeRole, ReviewerRole:
+	default:
+		return fmt.Errorf("invalid per-user role %q (expected one of %s, %s)", c.cfg.PerUser, AssigneeRole, ReviewerRole)
+	}
+
+	if c.perUserThresholds, err = parseThresholds(c.cfg.WarningPerUser, c.cfg.CriticalPerUser); err != nil {
+		return errors.Wrap(err, "per-user merge requests thresholds")
+	}
+
+	return nil
+}
+
+// usersOf returns the usernames holding role on m
+func usersOf(m MergeRequest, role string) []string {
+	if role == ReviewerRole {
+		return m.Reviewers
+	}
+	return m.Assignees
+}
+
+// userCount is the number of merge requests a user holds a role on
+type userCount struct {
+	Username string
+	Count    int
+}
+
+// perUserPerfLabel is the label of the number of merge requests of a user holding role
+func perUserPerfLabel(role, username string) string {
+	return sanitizePerfLabel(fmt.Sprintf("merge_requests_%s_%s", role, username))
+}
+
+// countPerUser returns the number of merge requests per user holding role,
+// sorted by decreasing count
+func countPerUser(mr []MergeRequest, role string) []userCount {
+	counts := make(map[string]int)
+	for _, m := range mr {
+		for _, u := range usersOf(m, role) {
+			counts[u]++
+		}
+	}
+
+	var uc []userCount
+	for u, n := range counts {
+		uc = append(uc, userCount{Username: u, Count: n})
+	}
+	sort.Slice(uc, func(i, j int) bool {
+		if uc[i].Count != uc[j].Count {
+			return uc[i].Count > uc[j].Count
+		}
+		return uc[i].Username < uc[j].Username
+	})
+	return uc
+}
+
+// checkMergeRequestCounts alerts on the size of the review backlog
+// rather than on the age of individual merge requests
+func (c nagiosProbe) checkMergeRequestCounts(mrChecker GitMergeRequestChecker) {
+	mr, _, err := c.fetchMergeRequests(mrChecker, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+
+	c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "Merge requests backlog within thresholds"))
+
+	defaultMetrics := append([]string{OpenedMergeRequestsMetric, OlderMergeRequestsMetric}, ageDistributionMetrics...)
+	c.checkMetrics(metricInput{opened: mr}, defaultMetrics...)
+	for _, m := range mr {
+		c.nagCheck.AddMergeRequest(m, nagiosplugin.OK)
+	}
+
+	if c.cfg.PerUser != "" {
+		counts := countPerUser(mr, c.cfg.PerUser)
+
+		var max int
+		var longOutput []string
+		for _, uc := range counts {
+			if uc.Count > max {
+				max = uc.Count
+			}
+			c.perUserThresholds.addPerfDatum(c.nagCheck, perUserPerfLabel(c.cfg.PerUser, uc.Username), "", float64(uc.Count))
+			if status := c.perUserThresholds.status(float64(uc.Count)); status != nagiosplugin.OK {
+				c.nagCheck.AddResultf(status, localize(c.cfg.Locale, "%s is %s of %d merge requests"), uc.Username, localize(c.cfg.Locale, c.cfg.PerUser), uc.Count)
+			}
+			longOutput = append(longOutput, fmt.Sprintf("%s: %d", uc.Username, uc.Count))
+		}
+
+		c.perUserThresholds.addPerfDatum(c.nagCheck, fmt.Sprintf("max_merge_requests_per_%s", c.cfg.PerUser), "", float64(max))
+		if len(longOutput) > 0 {
+			c.nagCheck.AddLongPluginOutput(fmt.Sprintf(localize(c.cfg.Locale, "Merge requests per %s:\n%s"), localize(c.cfg.Locale, c.cfg.PerUser), strings.Join(longOutput, "\n")))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package nagios
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DigestGroupByAuthor sends the stale merge requests to their author
+	DigestGroupByAuthor = "author"
+	// DigestGroupByAssignee sends the stale merge requests to each of their assignees
+	DigestGroupByAssignee = "assignee"
+)
+
+// DigestGroupings lists how the stale merge requests can be grouped into digests
+var DigestGroupings = []string{DigestGroupByAuthor, DigestGroupByAssignee}
+
+// Digest lists the stale merge requests of a user
+type Digest struct {
+	User  string // username of the author or assignee
+	Email string // recipient, empty if unknown
+	Role  string // one of the DigestGroupBy* constants
+	// MergeRequests are sorted by last activity, the oldest first
+	MergeRequests []MergeRequest
+}
+
+// buildDigests groups the opened merge requests of the configured project, projects
+// or group without activity for the warning delay by author or assignee
+func buildDigests(cfg ProbeConfig) ([]Digest, error) {
+	if cfg.ReportGroupBy != DigestGroupByAuthor && cfg.ReportGroupBy != DigestGroupByAssignee {
+		return nil, fmt.Errorf("unsupported grouping %q (expected one of %s)", cfg.ReportGroupBy, strings.Join(DigestGroupings, ","))
+	}
+
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.initSelection(); err != nil {
+		return nil, err
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "initializing %s checker", cfg.GitProvider)
+	}
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	stale := make(map[string][]MergeRequest)
+	now := time.Now()
+	for _, project := range projects {
+		mr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+			State:        MergeRequestStateOpened,
+			TargetBranch: cfg.TargetBranch,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing merge requests of %s", project)
+		}
+
+		mr, delays := probe.selectMergeRequests(mr)
+		for i, m := range mr {
+			if now.Sub(m.UpdatedAt) < delays[i].Warning {
+				continue
+			}
+
+			recipients := []string{m.Author}
+			if cfg.ReportGroupBy == DigestGroupByAssignee {
+				recipients = m.Assignees
+			}
+			if len(recipients) == 0 {
+				log.WithFields(log.Fields{
+					"project":       m.Project,
+					"merge-request": m.IID,
+				}).Debug("stale merge request without assignee")
+			}
+			for _, user := range recipients {
+				if _, ok := stale[user]; !ok {
+					users = append(users, user)
+				}
+				stale[user] = append(stale[user], m)
+			}
+		}
+	}
+
+	sort.Strings(users)
+	var digests []Digest
+	for _, user := range users {
+		mr := stale[user]
+		sort.SliceStable(mr, func(i, j int) bool {
+			return mr[i].UpdatedAt.Before(mr[j].UpdatedAt)
+		})
+		digests = append(digests, Digest{
+			User:          user,
+			Email:         digestRecipient(cfg, user),
+			Role:          cfg.ReportGroupBy,
+			MergeRequests: mr,
+		})
+	}
+	return digests, nil
+}
+
+// digestRecipient returns the email address of user, empty if unknown
+func digestRecipient(cfg ProbeConfig, user string) string {
+	if email, ok := cfg.ReportRecipients[user]; ok {
+		return email
+	}
+	// viper lowercases the keys of the maps read from the configuration file
+	if email, ok := cfg.ReportRecipients[strings.ToLower(user)]; ok {
+		return email
+	}
+	if cfg.ReportEmailDomain != "" {
+		return user + "@" + cfg.ReportEmailDomain
+	}
+	return ""
+}
+
+// isAllowedRecipient tells whether the domain of email is one of the allowed domains.
+// Every domain is allowed if none is configured.
+func isAllowedRecipient(allowedDomains []string, email string) bool {
+	if len(allowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range allowedDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
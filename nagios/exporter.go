@@ -0,0 +1,387 @@
+package nagios
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// exporterMetricsPath is where the exporter serves its metrics
+	exporterMetricsPath = "/metrics"
+	// exporterShutdownTimeout is the delay given to in-flight scrapes on shutdown
+	exporterShutdownTimeout = 5 * time.Second
+)
+
+// exporterMetric describes a gauge served by the exporter
+type exporterMetric struct {
+	Name string
+	Help string
+}
+
+const (
+	exporterOpenedMetric          = "git_merge_requests_opened"
+	exporterOldestAgeMetric       = "git_merge_requests_oldest_age_seconds"
+	exporterDraftMetric           = "git_merge_requests_draft"
+	exporterStaleMetric           = "git_merge_requests_stale"
+	exporterConflictingMetric     = "git_merge_requests_conflicting"
+	exporterFailedPipelineMetric  = "git_merge_requests_failed_pipeline"
+	exporterStateMetric           = "git_merge_requests_state"
+	exporterRefreshSuccessMetric  = "git_merge_requests_refresh_success"
+	exporterRefreshDurationMetric = "git_merge_requests_refresh_duration_seconds"
+	exporterLastRefreshMetric     = "git_merge_requests_last_refresh_timestamp_seconds"
+)
+
+// ExporterMetrics lists the gauges served by the exporter, in the order they are served
+var ExporterMetrics = []exporterMetric{
+	{exporterOpenedMetric, "Number of opened merge requests."},
+	{exporterOldestAgeMetric, "Time elapsed since the last activity of the oldest opened merge request."},
+	{exporterDraftMetric, "Number of opened draft merge requests."},
+	{exporterStaleMetric, "Number of opened merge requests without activity past the warning or critical delay."},
+	{exporterConflictingMetric, "Number of opened merge requests with conflicts (--check-conflicts)."},
+	{exporterFailedPipelineMetric, "Number of opened merge requests with a failed head pipeline (--check-failed-pipelines)."},
+	{exporterStateMetric, "Number of merge requests per state, merged and closed ones during the lookback window."},
+	{exporterRefreshSuccessMetric, "Whether the last refresh succeeded."},
+	{exporterRefreshDurationMetric, "Duration of the last refresh."},
+	{exporterLastRefreshMetric, "Time of the last successful refresh, in seconds since the epoch."},
+}
+
+// exporterSample is a value of a gauge
+type exporterSample struct {
+	metric string
+	labels [][2]string
+	value  float64
+}
+
+// Exporter serves metrics about the merge requests of the configured projects
+// in the Prometheus text format, refreshed in the background
+type Exporter struct {
+	cfg   ProbeConfig
+	probe nagiosProbe
+
+	mu              sync.RWMutex
+	samples         []exporterSample // of the last successful refresh
+	success         bool
+	refreshDuration time.Duration
+	lastRefresh     time.Time
+}
+
+// NewExporter validates cfg and returns an Exporter of the merge requests
+// selected the same way as the checks do
+func NewExporter(cfg ProbeConfig) (*Exporter, error) {
+	if !isSupportedGitProvider(cfg.GitProvider) {
+		return nil, fmt.Errorf("git provider %q is not supported", cfg.GitProvider)
+	}
+	if cfg.Project == "" && len(cfg.Projects) == 0 && cfg.Group == "" {
+		return nil, errors.New("a project, a list of projects or a group is required")
+	}
+	if cfg.RefreshInterval <= 0 {
+		return nil, errors.New("refresh interval must be positive")
+	}
+	if cfg.Lookback <= 0 {
+		return nil, errors.New("lookback must be positive")
+	}
+
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.initSelection(); err != nil {
+		return nil, err
+	}
+
+	return &Exporter{
+		cfg:   cfg,
+		probe: probe,
+	}, nil
+}
+
+// Serve refreshes the metrics every RefreshInterval and serves them
+// on ListenAddress until ctx is done
+func (e *Exporter) Serve(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(exporterMetricsPath, e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html><body><a href=%q>Metrics</a></body></html>\n", exporterMetricsPath)
+	})
+	srv := &http.Server{
+		Addr:    e.cfg.ListenAddress,
+		Handler: mux,
+	}
+
+	go e.refreshLoop(ctx)
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.WithField("listen-address", e.cfg.ListenAddress).Debug("serving metrics")
+		errChan <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return errors.Wrapf(err, "listening on %s", e.cfg.ListenAddress)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), exporterShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// refreshLoop refreshes the metrics right away then every RefreshInterval
+func (e *Exporter) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		e.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh collects the metrics within the configured timeout, past which
+// the requests of the refresh are canceled.
+// The metrics of the last successful refresh keep being served on failure.
+func (e *Exporter) refresh(ctx context.Context) {
+	type result struct {
+		samples []exporterSample
+		err     error
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		mrChecker, err := newGitMergeRequestChecker(e.cfg.withContext(ctx), e.probe.mrCheckerOptions())
+		if err != nil {
+			done <- result{err: errors.Wrapf(err, "initializing %s checker", e.cfg.GitProvider)}
+			return
+		}
+		samples, err := e.collect(mrChecker, start)
+		done <- result{samples, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = fmt.Errorf("timeout after %s", e.cfg.Timeout)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.refreshDuration = time.Since(start)
+	e.success = r.err == nil
+	if r.err != nil {
+		log.WithFields(log.Fields{
+			"error":        r.err,
+			"api-endpoint": e.cfg.APIEndpoint,
+		}).Error("fail to refresh metrics")
+		return
+	}
+	e.samples = r.samples
+	e.lastRefresh = time.Now()
+
+	log.WithField("duration", e.refreshDuration).Debug("metrics refreshed successfully")
+}
+
+// collect lists the merge requests of every project and computes their gauges
+func (e *Exporter) collect(mrChecker GitMergeRequestChecker, now time.Time) ([]exporterSample, error) {
+	projects, err := resolveProjects(e.cfg, mrChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	since := now.Add(-e.cfg.Lookback)
+	list := func(project, state string, updatedAfter time.Time) ([]MergeRequest, error) {
+		mr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+			State:        state,
+			TargetBranch: e.cfg.TargetBranch,
+			UpdatedAfter: updatedAfter,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing %s merge requests of %s", state, project)
+		}
+		return mr, nil
+	}
+
+	var samples []exporterSample
+	for _, project := range projects {
+		opened, err := list(project, MergeRequestStateOpened, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		merged, err := list(project, MergeRequestStateMerged, since)
+		if err != nil {
+			return nil, err
+		}
+		closed, err := list(project, MergeRequestStateClosed, since)
+		if err != nil {
+			return nil, err
+		}
+
+		opened, delays := e.probe.selectMergeRequests(opened)
+		merged, _ = e.probe.selectMergeRequests(mergedSince(merged, since))
+		closed, _ = e.probe.selectMergeRequests(closedSince(closed, since))
+
+		samples = append(samples, e.projectSamples(project, opened, delays, merged, closed, now)...)
+	}
+
+	return samples, nil
+}
+
+// projectSamples computes the gauges of a project, per target branch
+func (e *Exporter) projectSamples(project string, opened []MergeRequest, delays []mergeRequestDelays, merged, closed []MergeRequest, now time.Time) []exporterSample {
+	type branchMergeRequests struct {
+		opened []MergeRequest
+		delays []mergeRequestDelays
+		merged []MergeRequest
+		closed []MergeRequest
+	}
+
+	byBranch := make(map[string]*branchMergeRequests)
+	branch := func(name string) *branchMergeRequests {
+		if _, ok := byBranch[name]; !ok {
+			byBranch[name] = &branchMergeRequests{}
+		}
+		return byBranch[name]
+	}
+	// the configured target branch is always reported, even without merge requests
+	if e.cfg.TargetBranch != "" {
+		branch(e.cfg.TargetBranch)
+	}
+	for i, m := range opened {
+		b := branch(m.TargetBranch)
+		b.opened = append(b.opened, m)
+		b.delays = append(b.delays, delays[i])
+	}
+	for _, m := range merged {
+		b := branch(m.TargetBranch)
+		b.merged = append(b.merged, m)
+	}
+	for _, m := range closed {
+		b := branch(m.TargetBranch)
+		b.closed = append(b.closed, m)
+	}
+
+	names := make([]string, 0, len(byBranch))
+	for name := range byBranch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var samples []exporterSample
+	for _, name := range names {
+		b := byBranch[name]
+		labels := [][2]string{{"project", project}, {"target_branch", name}}
+		add := func(metric string, value float64, extra ...[2]string) {
+			samples = append(samples, exporterSample{
+				metric: metric,
+				labels: append(append([][2]string{}, labels...), extra...),
+				value:  value,
+			})
+		}
+
+		in := metricInput{opened: b.opened, now: now}
+		add(exporterOpenedMetric, float64(len(b.opened)))
+		if stats := newDurationStats(in.ages()); stats.Count > 0 {
+			add(exporterOldestAgeMetric, stats.Max.Seconds())
+		}
+		add(exporterDraftMetric, countMergeRequests(b.opened, func(m MergeRequest) bool { return m.Draft }))
+
+		var warning, critical int
+		for i, m := range b.opened {
+			tSinceLastUpdate := now.Sub(m.UpdatedAt)
+			if tSinceLastUpdate >= b.delays[i].Critical {
+				critical++
+			} else if tSinceLastUpdate >= b.delays[i].Warning {
+				warning++
+			}
+		}
+		add(exporterStaleMetric, float64(warning), [2]string{"severity", "warning"})
+		add(exporterStaleMetric, float64(critical), [2]string{"severity", "critical"})
+
+		if e.cfg.CheckConflicts {
+			add(exporterConflictingMetric, countMergeRequests(b.opened, func(m MergeRequest) bool { return m.HasConflicts }))
+		}
+		if e.cfg.CheckFailedPipelines {
+			add(exporterFailedPipelineMetric, countMergeRequests(b.opened, func(m MergeRequest) bool { return m.HasFailedPipeline() }))
+		}
+
+		add(exporterStateMetric, float64(len(b.opened)), [2]string{"state", MergeRequestStateOpened})
+		add(exporterStateMetric, float64(len(b.merged)), [2]string{"state", MergeRequestStateMerged})
+		add(exporterStateMetric, float64(len(b.closed)), [2]string{"state", MergeRequestStateClosed})
+	}
+
+	return samples
+}
+
+// ServeHTTP writes the metrics of the last refresh in the Prometheus text format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	samples := append([]exporterSample{}, e.samples...)
+	var success float64
+	if e.success {
+		success = 1
+	}
+	samples = append(samples,
+		exporterSample{metric: exporterRefreshSuccessMetric, value: success},
+		exporterSample{metric: exporterRefreshDurationMetric, value: e.refreshDuration.Seconds()},
+	)
+	if !e.lastRefresh.IsZero() {
+		samples = append(samples, exporterSample{
+			metric: exporterLastRefreshMetric,
+			value:  float64(e.lastRefresh.UnixNano()) / float64(time.Second),
+		})
+	}
+	e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeExporterSamples(w, ExporterMetrics, samples)
+}
+
+// writeExporterSamples writes samples in the Prometheus text format,
+// grouped by metric in the order of metrics
+func writeExporterSamples(w io.Writer, metrics []exporterMetric, samples []exporterSample) {
+	for _, metric := range metrics {
+		headerWritten := false
+		for _, s := range samples {
+			if s.metric != metric.Name {
+				continue
+			}
+			if !headerWritten {
+				fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", metric.Name, metric.Help, metric.Name)
+				headerWritten = true
+			}
+
+			fmt.Fprint(w, metric.Name)
+			if len(s.labels) > 0 {
+				var labels []string
+				for _, l := range s.labels {
+					labels = append(labels, fmt.Sprintf(`%s="%s"`, l[0], labelValueEscaper.Replace(l[1])))
+				}
+				fmt.Fprintf(w, "{%s}", strings.Join(labels, ","))
+			}
+			fmt.Fprintf(w, " %g\n", s.value)
+		}
+	}
+}
+
+// labelValueEscaper escapes label values as the Prometheus text format expects
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,146 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExporterProjectSamples(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	e := &Exporter{cfg: ProbeConfig{TargetBranch: "main", CheckConflicts: true}}
+
+	delays := mergeRequestDelays{Warning: 6 * time.Hour, Critical: 24 * time.Hour}
+	opened := []MergeRequest{
+		{IID: 1, TargetBranch: `fix"es\`, UpdatedAt: now.Add(-time.Hour), Draft: true},
+		{IID: 2, TargetBranch: `fix"es\`, UpdatedAt: now.Add(-8 * time.Hour), HasConflicts: true},
+		{IID: 3, TargetBranch: "develop", UpdatedAt: now.Add(-48 * time.Hour)},
+	}
+	merged := []MergeRequest{{IID: 4, TargetBranch: "develop"}}
+
+	var buf bytes.Buffer
+	samples := e.projectSamples("group/project", opened, []mergeRequestDelays{delays, delays, delays}, merged, nil, now)
+	writeExporterSamples(&buf, ExporterMetrics, samples)
+
+	// the configured target branch is reported without merge requests,
+	// the branches in alphabetical order
+	want := `# HELP git_merge_requests_opened Number of opened merge requests.
+# TYPE git_merge_requests_opened gauge
+git_merge_requests_opened{project="group/project",target_branch="develop"} 1
+git_merge_requests_opened{project="group/project",target_branch="fix\"es\\"} 2
+git_merge_requests_opened{project="group/project",target_branch="main"} 0
+# HELP git_merge_requests_oldest_age_seconds Time elapsed since the last activity of the oldest opened merge request.
+# TYPE git_merge_requests_oldest_age_seconds gauge
+git_merge_requests_oldest_age_seconds{project="group/project",target_branch="develop"} 172800
+git_merge_requests_oldest_age_seconds{project="group/project",target_branch="fix\"es\\"} 28800
+# HELP git_merge_requests_draft Number of opened draft merge requests.
+# TYPE git_merge_requests_draft gauge
+git_merge_requests_draft{project="group/project",target_branch="develop"} 0
+git_merge_requests_draft{project="group/project",target_branch="fix\"es\\"} 1
+git_merge_requests_draft{project="group/project",target_branch="main"} 0
+# HELP git_merge_requests_stale Number of opened merge requests without activity past the warning or critical delay.
+# TYPE git_merge_requests_stale gauge
+git_merge_requests_stale{project="group/project",target_branch="develop",severity="warning"} 0
+git_merge_requests_stale{project="group/project",target_branch="develop",severity="critical"} 1
+git_merge_requests_stale{project="group/project",target_branch="fix\"es\\",severity="warning"} 1
+git_merge_requests_stale{project="group/project",target_branch="fix\"es\\",severity="critical"} 0
+git_merge_requests_stale{project="group/project",target_branch="main",severity="warning"} 0
+git_merge_requests_stale{project="group/project",target_branch="main",severity="critical"} 0
+# HELP git_merge_requests_conflicting Number of opened merge requests with conflicts (--check-conflicts).
+# TYPE git_merge_requests_conflicting gauge
+git_merge_requests_conflicting{project="group/project",target_branch="develop"} 0
+git_merge_requests_conflicting{project="group/project",target_branch="fix\"es\\"} 1
+git_merge_requests_conflicting{project="group/project",target_branch="main"} 0
+# HELP git_merge_requests_state Number of merge requests per state, merged and closed ones during the lookback window.
+# TYPE git_merge_requests_state gauge
+git_merge_requests_state{project="group/project",target_branch="develop",state="opened"} 1
+git_merge_requests_state{project="group/project",target_branch="develop",state="merged"} 1
+git_merge_requests_state{project="group/project",target_branch="develop",state="closed"} 0
+git_merge_requests_state{project="group/project",target_branch="fix\"es\\",state="opened"} 2
+git_merge_requests_state{project="group/project",target_branch="fix\"es\\",state="merged"} 0
+git_merge_requests_state{project="group/project",target_branch="fix\"es\\",state="closed"} 0
+git_merge_requests_state{project="group/project",target_branch="main",state="opened"} 0
+git_merge_requests_state{project="group/project",target_branch="main",state="merged"} 0
+git_merge_requests_state{project="group/project",target_branch="main",state="closed"} 0
+`
+	if buf.String() != want {
+		t.Errorf("samples =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteExporterSamplesEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	writeExporterSamples(&buf, []exporterMetric{{exporterRefreshSuccessMetric, "Whether the last refresh succeeded."}}, []exporterSample{
+		{metric: exporterRefreshSuccessMetric, labels: [][2]string{{"project", "a\\b\"c\nd"}}, value: 1},
+		{metric: exporterOpenedMetric, value: 3}, // not listed in the metrics
+	})
+
+	want := `# HELP git_merge_requests_refresh_success Whether the last refresh succeeded.
+# TYPE git_merge_requests_refresh_success gauge
+git_merge_requests_refresh_success{project="a\\b\"c\nd"} 1
+`
+	if buf.String() != want {
+		t.Errorf("samples =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestExporterRefreshTimeout(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		canceled int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v4/" {
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+			return
+		}
+		// a provider slower than the timeout
+		select {
+		case <-r.Context().Done():
+			mu.Lock()
+			canceled++
+			mu.Unlock()
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	e, err := NewExporter(ProbeConfig{
+		APIEndpoint:             srv.URL,
+		GitProvider:             GitlabGitProvider,
+		Project:                 "group/project",
+		Timeout:                 100 * time.Millisecond,
+		WarningLastUpdateDelay:  6 * time.Hour,
+		CriticalLastUpdateDelay: 24 * time.Hour,
+		RefreshInterval:         time.Minute,
+		Lookback:                24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.refresh(context.Background())
+	if e.success {
+		t.Error("refresh succeeded, want a timeout")
+	}
+
+	// the request in flight is canceled rather than left running
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := canceled
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the provider request was not canceled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package nagios
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockFile waits for an exclusive lock on file, created if missing,
+// until ctx is done. Closing the returned file releases the lock.
+func lockFile(ctx context.Context, file string) (*os.File, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", file)
+	}
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "locking %s", file)
+		}
+		if locked {
+			return f, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, errors.Wrapf(ctx.Err(), "locking %s", file)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
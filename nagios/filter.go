@@ -0,0 +1,253 @@
+package nagios
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type filterType int
+
+const (
+	filterBool filterType = iota
+	filterNumber
+	filterString
+	filterStringList
+	filterDuration
+)
+
+func (t filterType) String() string {
+	switch t {
+	case filterBool:
+		return "bool"
+	case filterNumber:
+		return "number"
+	case filterString:
+		return "string"
+	case filterStringList:
+		return "list"
+	case filterDuration:
+		return "duration"
+	}
+	return "invalid"
+}
+
+// FilterField describes a merge request field available in filter expressions
+type FilterField struct {
+	Name        string
+	Description string
+
+	typ   filterType
+	value func(m MergeRequest, now time.Time) interface{}
+}
+
+// FilterFields lists the merge request fields that can be used in filter expressions
+var FilterFields = []FilterField{
+	{Name: "id", typ: filterNumber, Description: "merge request global ID", value: func(m MergeRequest, _ time.Time) interface{} { return float64(m.ID) }},
+	{Name: "iid", typ: filterNumber, Description: "merge request number within the project", value: func(m MergeRequest, _ time.Time) interface{} { return float64(m.IID) }},
+	{Name: "title", typ: filterString, Description: "merge request title", value: func(m MergeRequest, _ time.Time) interface{} { return m.Title }},
+	{Name: "author", typ: filterString, Description: "author username", value: func(m MergeRequest, _ time.Time) interface{} { return m.Author }},
+	{Name: "target_branch", typ: filterString, Description: "target branch", value: func(m MergeRequest, _ time.Time) interface{} { return m.TargetBranch }},
+	{Name: "web_url", typ: filterString, Description: "merge request URL", value: func(m MergeRequest, _ time.Time) interface{} { return m.WebURL }},
+	{Name: "labels", typ: filterStringList, Description: "merge request labels", value: func(m MergeRequest, _ time.Time) interface{} { return m.Labels }},
+	{Name: "draft", typ: filterBool, Description: "draft / work in progress flag", value: func(m MergeRequest, _ time.Time) interface{} { return m.Draft }},
+	{Name: "has_conflicts", typ: filterBool, Description: "merge request has conflicts (Github requires --check-conflicts)", value: func(m MergeRequest, _ time.Time) interface{} { return m.HasConflicts }},
+	{Name: "pipeline_status", typ: filterString, Description: "head pipeline status (requires --check-failed-pipelines)", value: func(m MergeRequest, _ time.Time) interface{} { return m.PipelineStatus }},
+	{Name: "pipeline_failed", typ: filterBool, Description: "head pipeline has failed (requires --check-failed-pipelines)", value: func(m MergeRequest, _ time.Time) interface{} { return m.HasFailedPipeline() }},
+	{Name: "age_created", typ: filterDuration, Description: "time elapsed since the merge request creation", value: func(m MergeRequest, now time.Time) interface{} { return now.Sub(m.CreatedAt) }},
+	{Name: "age_updated", typ: filterDuration, Description: "time elapsed since the merge request last activity", value: func(m MergeRequest, now time.Time) interface{} { return now.Sub(m.UpdatedAt) }},
+}
+
+// TypeName returns the name of the field type
+func (f FilterField) TypeName() string {
+	return f.typ.String()
+}
+
+func lookupFilterField(name string) (FilterField, bool) {
+	for _, f := range FilterFields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FilterField{}, false
+}
+
+// FilterFieldsHelp returns a human readable description of
+// the filter expressions syntax and of the available fields
+func FilterFieldsHelp() string {
+	var sb strings.Builder
+	sb.WriteString("Filter expressions support the ! && || == != < <= > >= =~ and in operators, parentheses,\n")
+	sb.WriteString("\"strings\", numbers, true / false and durations (30m, 2h, 3d, 1w).\n\n")
+	sb.WriteString("Available fields:\n")
+	for _, f := range FilterFields {
+		fmt.Fprintf(&sb, "  %-16s %-9s %s\n", f.Name, f.TypeName(), f.Description)
+	}
+	return sb.String()
+}
+
+// Filter is a compiled filter expression
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// ParseFilter compiles a filter expression such as
+//
+//	!draft && "security" in labels && age_updated > 2h
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := filterParser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, FilterSyntaxError{t.pos, fmt.Sprintf("unexpected %s", t)}
+	}
+
+	if root.Type() != filterBool {
+		return nil, FilterSyntaxError{1, fmt.Sprintf("expression must be a bool, got %s", root.Type())}
+	}
+
+	return &Filter{expr: expr, root: root}, nil
+}
+
+func (f Filter) String() string {
+	return f.expr
+}
+
+// Match evaluates the filter against m
+func (f Filter) Match(m MergeRequest, now time.Time) bool {
+	return f.root.Eval(m, now).(bool)
+}
+
+// filterNode is a node of a type checked expression tree.
+// Eval can therefore never fail.
+type filterNode interface {
+	Type() filterType
+	Eval(m MergeRequest, now time.Time) interface{}
+}
+
+type literalNode struct {
+	typ   filterType
+	value interface{}
+}
+
+func (n literalNode) Type() filterType                             { return n.typ }
+func (n literalNode) Eval(_ MergeRequest, _ time.Time) interface{} { return n.value }
+
+type fieldNode struct {
+	field FilterField
+}
+
+func (n fieldNode) Type() filterType                               { return n.field.typ }
+func (n fieldNode) Eval(m MergeRequest, now time.Time) interface{} { return n.field.value(m, now) }
+
+type notNode struct {
+	operand filterNode
+}
+
+func (n notNode) Type() filterType { return filterBool }
+func (n notNode) Eval(m MergeRequest, now time.Time) interface{} {
+	return !n.operand.Eval(m, now).(bool)
+}
+
+type logicalNode struct {
+	op          string
+	left, right filterNode
+}
+
+func (n logicalNode) Type() filterType { return filterBool }
+func (n logicalNode) Eval(m MergeRequest, now time.Time) interface{} {
+	left := n.left.Eval(m, now).(bool)
+	if n.op == "&&" {
+		return left && n.right.Eval(m, now).(bool)
+	}
+	return left || n.right.Eval(m, now).(bool)
+}
+
+type inNode struct {
+	needle, haystack filterNode
+}
+
+func (n inNode) Type() filterType { return filterBool }
+func (n inNode) Eval(m MergeRequest, now time.Time) interface{} {
+	needle := n.needle.Eval(m, now).(string)
+	switch haystack := n.haystack.Eval(m, now).(type) {
+	case []string:
+		for _, s := range haystack {
+			if s == needle {
+				return true
+			}
+		}
+		return false
+	case string:
+		return strings.Contains(haystack, needle)
+	}
+	return false
+}
+
+type regexpNode struct {
+	operand filterNode
+	re      *regexp.Regexp
+}
+
+func (n regexpNode) Type() filterType { return filterBool }
+func (n regexpNode) Eval(m MergeRequest, now time.Time) interface{} {
+	return n.re.MatchString(n.operand.Eval(m, now).(string))
+}
+
+type comparisonNode struct {
+	op          string
+	left, right filterNode
+}
+
+func (n comparisonNode) Type() filterType { return filterBool }
+func (n comparisonNode) Eval(m MergeRequest, now time.Time) interface{} {
+	left, right := n.left.Eval(m, now), n.right.Eval(m, now)
+
+	// cmp is negative, zero or positive like strings.Compare
+	var cmp int
+	switch l := left.(type) {
+	case bool:
+		if l != right.(bool) {
+			cmp = 1
+		}
+	case string:
+		cmp = strings.Compare(l, right.(string))
+	case float64:
+		cmp = compareFloat(l, right.(float64))
+	case time.Duration:
+		cmp = compareFloat(float64(l), float64(right.(time.Duration)))
+	}
+
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
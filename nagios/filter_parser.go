@@ -0,0 +1,364 @@
+package nagios
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type filterTokenKind int
+
+const (
+	tokEOF filterTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOperator
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int // 1 based column
+}
+
+func (t filterToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// FilterSyntaxError is returned when a filter expression cannot be parsed
+type FilterSyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e FilterSyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!"}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokLParen, text: "(", pos: pos})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokRParen, text: ")", pos: pos})
+			i++
+
+		case r == '"':
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, FilterSyntaxError{pos, "unterminated string"}
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokString, text: sb.String(), pos: pos})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokNumber
+			// a number directly followed by a unit is a duration (2h, 1h30m, 3d)
+			if i < len(runes) && unicode.IsLetter(runes[i]) {
+				kind = tokDuration
+				for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.') {
+					i++
+				}
+			}
+			tokens = append(tokens, filterToken{kind: kind, text: string(runes[start:i]), pos: pos})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			kind := tokIdent
+			if word == "in" {
+				kind = tokOperator
+			}
+			tokens = append(tokens, filterToken{kind: kind, text: word, pos: pos})
+
+		default:
+			matched := false
+			for _, op := range filterOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, filterToken{kind: tokOperator, text: op, pos: pos})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, FilterSyntaxError{pos, fmt.Sprintf("unexpected character %q", r)}
+			}
+		}
+	}
+
+	return append(tokens, filterToken{kind: tokEOF, pos: len(runes) + 1}), nil
+}
+
+// filterParser is a recursive descent parser for the following grammar:
+//
+//	expr       := and ( "||" and )*
+//	and        := unary ( "&&" unary )*
+//	unary      := "!" unary | comparison
+//	comparison := operand ( ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "in" ) operand )?
+//	operand    := literal | field | "(" expr ")"
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) acceptOperator(ops ...string) (filterToken, bool) {
+	t := p.peek()
+	if t.kind != tokOperator {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+func (p *filterParser) parseExpr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator("||")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogicalNode(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator("&&")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogicalNode(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if op, ok := p.acceptOperator("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.Type() != filterBool {
+			return nil, FilterSyntaxError{op.pos, fmt.Sprintf("operator ! expects a bool operand, got %s", operand.Type())}
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.acceptOperator("==", "!=", "<", "<=", ">", ">=", "=~", "in")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return newComparisonNode(op, left, right)
+}
+
+func (p *filterParser) parseOperand() (filterNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, FilterSyntaxError{closing.pos, fmt.Sprintf("expected \")\", got %s", closing)}
+		}
+		return node, nil
+
+	case tokString:
+		return literalNode{filterString, t.text}, nil
+
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, FilterSyntaxError{t.pos, fmt.Sprintf("invalid number %q", t.text)}
+		}
+		return literalNode{filterNumber, f}, nil
+
+	case tokDuration:
+		d, err := parseLongDuration(t.text)
+		if err != nil {
+			return nil, FilterSyntaxError{t.pos, fmt.Sprintf("invalid duration %q", t.text)}
+		}
+		return literalNode{filterDuration, d}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalNode{filterBool, true}, nil
+		case "false":
+			return literalNode{filterBool, false}, nil
+		}
+		field, ok := lookupFilterField(t.text)
+		if !ok {
+			return nil, FilterSyntaxError{t.pos, fmt.Sprintf("unknown field %q", t.text)}
+		}
+		return fieldNode{field}, nil
+	}
+
+	return nil, FilterSyntaxError{t.pos, fmt.Sprintf("expected a field, a literal or \"(\", got %s", t)}
+}
+
+func newLogicalNode(op filterToken, left, right filterNode) (filterNode, error) {
+	if left.Type() != filterBool || right.Type() != filterBool {
+		return nil, FilterSyntaxError{op.pos, fmt.Sprintf("operator %s expects bool operands, got %s and %s", op.text, left.Type(), right.Type())}
+	}
+	return logicalNode{op: op.text, left: left, right: right}, nil
+}
+
+func newComparisonNode(op filterToken, left, right filterNode) (filterNode, error) {
+	lt, rt := left.Type(), right.Type()
+	typeErr := FilterSyntaxError{op.pos, fmt.Sprintf("operator %s cannot compare %s and %s", op.text, lt, rt)}
+
+	switch op.text {
+	case "in":
+		if lt != filterString || (rt != filterStringList && rt != filterString) {
+			return nil, typeErr
+		}
+		return inNode{left, right}, nil
+
+	case "=~":
+		lit, ok := right.(literalNode)
+		if lt != filterString || !ok || rt != filterString {
+			return nil, FilterSyntaxError{op.pos, "operator =~ expects a string field and a string literal regexp"}
+		}
+		re, err := regexp.Compile(lit.value.(string))
+		if err != nil {
+			return nil, FilterSyntaxError{op.pos, fmt.Sprintf("invalid regexp: %s", err)}
+		}
+		return regexpNode{left, re}, nil
+
+	case "==", "!=":
+		if lt != rt || lt == filterStringList {
+			return nil, typeErr
+		}
+
+	default:
+		if lt != rt || (lt != filterNumber && lt != filterDuration && lt != filterString) {
+			return nil, typeErr
+		}
+	}
+
+	return comparisonNode{op: op.text, left: left, right: right}, nil
+}
+
+var longDurationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseLongDuration parses a duration the same way time.ParseDuration does
+// but also understands days (d) and weeks (w), e.g. 1w2d or 3d12h
+func parseLongDuration(s string) (time.Duration, error) {
+	var total time.Duration
+
+	for s != "" {
+		i := strings.IndexAny(s, "dw")
+		if i < 0 {
+			d, err := time.ParseDuration(s)
+			return total + d, err
+		}
+
+		// days and weeks must come first (1h2d is not allowed)
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += time.Duration(n * float64(longDurationUnits[s[i:i+1]]))
+		s = s[i+1:]
+	}
+
+	return total, nil
+}
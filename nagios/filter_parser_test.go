@@ -0,0 +1,131 @@
+package nagios
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLongDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "30m", want: 30 * time.Minute},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "3d", want: 72 * time.Hour},
+		{in: "1w", want: 7 * 24 * time.Hour},
+		{in: "1w2d", want: 9 * 24 * time.Hour},
+		{in: "3d12h", want: 84 * time.Hour},
+		{in: "1.5d", want: 36 * time.Hour},
+		{in: "1h2d", wantErr: true},
+		{in: "xd", wantErr: true},
+		{in: "2y", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLongDuration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseLongDuration(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseLongDuration(%q) returned %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLongDuration(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := MergeRequest{
+		ID:           1001,
+		IID:          12,
+		Title:        "WIP: fix the build",
+		Author:       "alice",
+		TargetBranch: "master",
+		Labels:       []string{"security", "backend"},
+		Draft:        true,
+		CreatedAt:    now.Add(-10 * 24 * time.Hour),
+		UpdatedAt:    now.Add(-3 * time.Hour),
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`draft`, true},
+		{`!draft`, false},
+		{`iid == 12`, true},
+		{`iid >= 13`, false},
+		{`author == "alice" && target_branch != "develop"`, true},
+		{`"security" in labels`, true},
+		{`"frontend" in labels`, false},
+		{`"fix" in title`, true},
+		{`title =~ "^WIP:"`, true},
+		{`age_updated > 2h`, true},
+		{`age_updated > 1d`, false},
+		{`age_created >= 1w && age_created < 2w`, true},
+		{`age_created > 1w3d`, false},
+		// && binds tighter than ||
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		// ! binds tighter than &&
+		{`!false && false`, false},
+		{`!(false && false)`, true},
+		{`false || !draft || iid == 12`, true},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) returned %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(m, now); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`draft && 1`, "column 7: operator && expects bool operands, got bool and number"},
+		{`iid == "12"`, "column 5: operator == cannot compare number and string"},
+		{`age_updated > 2`, "column 13: operator > cannot compare duration and number"},
+		{`labels == "security"`, "column 8: operator == cannot compare list and string"},
+		{`"security" in iid`, "column 12: operator in cannot compare string and number"},
+		{`!iid`, "column 1: operator ! expects a bool operand, got number"},
+		{`title =~ author`, "column 7: operator =~ expects a string field and a string literal regexp"},
+		{`title =~ "("`, "column 7: invalid regexp: error parsing regexp: missing closing ): `(`"},
+		{`reviewer == "bob"`, `column 1: unknown field "reviewer"`},
+		{`age_updated > 2x`, `column 15: invalid duration "2x"`},
+		{`author == "alice`, "column 11: unterminated string"},
+		{`draft # 1`, "column 7: unexpected character '#'"},
+		{`(draft`, "column 7: expected \")\", got end of expression"},
+		{`title`, "column 1: expression must be a bool, got string"},
+		{`draft draft`, `column 7: unexpected "draft"`},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.expr)
+		if err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want %q", tt.expr, tt.want)
+			continue
+		}
+		if _, ok := err.(FilterSyntaxError); !ok {
+			t.Errorf("ParseFilter(%q) returned a %T, want a FilterSyntaxError", tt.expr, err)
+		}
+		if err.Error() != tt.want {
+			t.Errorf("ParseFilter(%q) = %q, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
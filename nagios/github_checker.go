@@ -0,0 +1,374 @@
+package nagios
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// https://docs.github.com/en/rest/reference/pulls#list-pull-requests
+	githubPullRequestsOpenedState = "open"
+	githubPullRequestsClosedState = "closed"
+
+	// https://docs.github.com/en/rest/reference/pulls#get-a-pull-request
+	githubDirtyMergeableState = "dirty"
+)
+
+type githubPullRequest struct {
+	ID             int        `json:"id"`
+	Number         int        `json:"number"`
+	Title          string     `json:"title"`
+	HTMLURL        string     `json:"html_url"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Mergeable      *bool      `json:"mergeable"`
+	MergeableState string     `json:"mergeable_state"`
+	MergedAt       *time.Time `json:"merged_at"`
+	ClosedAt       *time.Time `json:"closed_at"`
+	Draft          bool       `json:"draft"`
+	User           githubUser `json:"user"`
+	Labels         []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees          []githubUser `json:"assignees"`
+	RequestedReviewers []githubUser `json:"requested_reviewers"`
+	Head               struct {
+		SHA string `json:"sha"`
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubCombinedStatus struct {
+	State      string `json:"state"`
+	TotalCount int    `json:"total_count"`
+}
+
+type githubCheckRuns struct {
+	TotalCount int `json:"total_count"`
+	CheckRuns  []struct {
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_runs"`
+}
+
+type githubProjectMRChecker struct {
+	client *githubClient
+	opts   mrCheckerOptions
+}
+
+func newGithubProjectMRChecker(endpoint, apiToken string, httpClient *http.Client, opts mrCheckerOptions) (*githubProjectMRChecker, error) {
+	c, err := newGithubClient(endpoint, apiToken, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &githubProjectMRChecker{
+		client: c,
+		opts:   opts,
+	}, nil
+}
+
+func (g githubProjectMRChecker) CheckMergeRequests(project string, targetBranch string) ([]MergeRequest, error) {
+	return g.ListMergeRequests(project, ListMergeRequestsOptions{
+		State:        MergeRequestStateOpened,
+		TargetBranch: targetBranch,
+	})
+}
+
+func (g githubProjectMRChecker) ListMergeRequests(project string, lopts ListMergeRequestsOptions) ([]MergeRequest, error) {
+	var gmr []MergeRequest
+
+	query := url.Values{}
+	query.Set("state", githubPullRequestsOpenedState)
+	if lopts.State == MergeRequestStateMerged || lopts.State == MergeRequestStateClosed {
+		query.Set("state", githubPullRequestsClosedState)
+	}
+	if lopts.TargetBranch != "" {
+		query.Set("base", lopts.TargetBranch)
+	}
+	// most recently updated first, so that we can stop as soon as UpdatedAfter is reached
+	query.Set("sort", "updated")
+	query.Set("direction", "desc")
+
+	err := g.client.list(fmt.Sprintf("repos/%s/pulls", project), query, func(item json.RawMessage) error {
+		var pr githubPullRequest
+		if err := json.Unmarshal(item, &pr); err != nil {
+			return err
+		}
+
+		if !lopts.UpdatedAfter.IsZero() && pr.UpdatedAt.Before(lopts.UpdatedAfter) {
+			return errStopListing
+		}
+
+		// github has no merged state, merged pull requests are closed ones with a merge date
+		switch lopts.State {
+		case MergeRequestStateMerged:
+			if pr.MergedAt == nil {
+				return nil
+			}
+		case MergeRequestStateClosed:
+			if pr.MergedAt != nil {
+				return nil
+			}
+		}
+
+		// mergeable is only computed by the single pull request API
+		if g.opts.WithMergeability {
+			if err := g.client.get(fmt.Sprintf("repos/%s/pulls/%d", project, pr.Number), nil, &pr); err != nil {
+				return errors.Wrapf(err, "getting pull-request %d", pr.Number)
+			}
+		}
+
+		m := MergeRequest{
+			ID:           pr.ID,
+			IID:          pr.Number,
+			Project:      project,
+			CreatedAt:    pr.CreatedAt,
+			UpdatedAt:    pr.UpdatedAt,
+			Title:        pr.Title,
+			WebURL:       pr.HTMLURL,
+			TargetBranch: pr.Base.Ref,
+			SourceBranch: pr.Head.Ref,
+			Author:       pr.User.Login,
+			Draft:        pr.Draft,
+			State:        MergeRequestStateOpened,
+			HasConflicts: pr.MergeableState == githubDirtyMergeableState || (pr.Mergeable != nil && !*pr.Mergeable),
+		}
+		for _, l := range pr.Labels {
+			m.Labels = append(m.Labels, l.Name)
+		}
+		m.Assignees = githubLogins(pr.Assignees)
+		m.Reviewers = githubLogins(pr.RequestedReviewers)
+		if pr.ClosedAt != nil {
+			m.State = MergeRequestStateClosed
+			m.ClosedAt = *pr.ClosedAt
+		}
+		if pr.MergedAt != nil {
+			m.State = MergeRequestStateMerged
+			m.MergedAt = *pr.MergedAt
+		}
+
+		if g.opts.WithPipelineStatus {
+			status, err := g.pipelineStatus(project, pr.Head.SHA)
+			if err != nil {
+				return errors.Wrapf(err, "getting pull-request %d statuses", pr.Number)
+			}
+			m.PipelineStatus = status
+		}
+
+		// the user who closed a pull request is only part of the single issue API
+		if g.opts.WithCloser && m.State == MergeRequestStateClosed {
+			var issue struct {
+				ClosedBy *githubUser `json:"closed_by"`
+			}
+			if err := g.client.get(fmt.Sprintf("repos/%s/issues/%d", project, pr.Number), nil, &issue); err != nil {
+				return errors.Wrapf(err, "getting pull-request %d issue", pr.Number)
+			}
+			if issue.ClosedBy != nil {
+				m.ClosedBy = issue.ClosedBy.Login
+			}
+		}
+
+		if g.opts.WithFirstReview {
+			firstReviewAt, err := g.firstReviewAt(project, m)
+			if err != nil {
+				return errors.Wrapf(err, "getting pull-request %d reviews", pr.Number)
+			}
+			m.FirstReviewAt = firstReviewAt
+		}
+
+		gmr = append(gmr, m)
+		return nil
+	})
+	if err != nil {
+		return gmr, errors.Wrap(err, "listing project pull-requests")
+	}
+
+	return gmr, nil
+}
+
+// firstReviewAt returns the date of the first review submitted
+// by somebody else than the pull request author
+func (g githubProjectMRChecker) firstReviewAt(project string, m MergeRequest) (time.Time, error) {
+	var first time.Time
+
+	err := g.client.list(fmt.Sprintf("repos/%s/pulls/%d/reviews", project, m.IID), nil, func(item json.RawMessage) error {
+		var review struct {
+			User        githubUser `json:"user"`
+			SubmittedAt *time.Time `json:"submitted_at"`
+		}
+		if err := json.Unmarshal(item, &review); err != nil {
+			return err
+		}
+
+		if review.SubmittedAt == nil || review.User.Login == m.Author {
+			return nil
+		}
+		if first.IsZero() || review.SubmittedAt.Before(first) {
+			first = *review.SubmittedAt
+		}
+		return nil
+	})
+
+	return first, err
+}
+
+func (g githubProjectMRChecker) ListGroupProjects(group string) ([]string, error) {
+	var projects []string
+
+	err := g.client.list(fmt.Sprintf("orgs/%s/repos", group), nil, func(item json.RawMessage) error {
+		var repo struct {
+			FullName string `json:"full_name"`
+			Archived bool   `json:"archived"`
+		}
+		if err := json.Unmarshal(item, &repo); err != nil {
+			return err
+		}
+		if !repo.Archived {
+			projects = append(projects, repo.FullName)
+		}
+		return nil
+	})
+	if err != nil {
+		return projects, errors.Wrap(err, "listing organization repositories")
+	}
+
+	return projects, nil
+}
+
+func (g githubProjectMRChecker) ListBranches(project string) ([]Branch, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+		HTMLURL       string `json:"html_url"`
+	}
+	if err := g.client.get(fmt.Sprintf("repos/%s", project), nil, &repo); err != nil {
+		return nil, errors.Wrap(err, "getting repository")
+	}
+
+	var branches []Branch
+	err := g.client.list(fmt.Sprintf("repos/%s/branches", project), nil, func(item json.RawMessage) error {
+		var b struct {
+			Name      string `json:"name"`
+			Protected bool   `json:"protected"`
+			Commit    struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(item, &b); err != nil {
+			return err
+		}
+
+		// the commit date is only part of the single commit API
+		var commit struct {
+			Commit struct {
+				Committer struct {
+					Date time.Time `json:"date"`
+				} `json:"committer"`
+			} `json:"commit"`
+		}
+		if err := g.client.get(fmt.Sprintf("repos/%s/commits/%s", project, b.Commit.SHA), nil, &commit); err != nil {
+			return errors.Wrapf(err, "getting branch %s last commit", b.Name)
+		}
+
+		branches = append(branches, Branch{
+			Name:         b.Name,
+			Project:      project,
+			WebURL:       fmt.Sprintf("%s/tree/%s", repo.HTMLURL, b.Name),
+			Protected:    b.Protected,
+			Default:      b.Name == repo.DefaultBranch,
+			LastCommitAt: commit.Commit.Committer.Date,
+		})
+		return nil
+	})
+	if err != nil {
+		return branches, errors.Wrap(err, "listing repository branches")
+	}
+
+	return branches, nil
+}
+
+// pipelineStatus merges the legacy combined commit status and the
+// check-runs of a commit into our normalized pipeline status
+func (g githubProjectMRChecker) pipelineStatus(project, sha string) (string, error) {
+	var combined githubCombinedStatus
+	if err := g.client.get(fmt.Sprintf("repos/%s/commits/%s/status", project, sha), nil, &combined); err != nil {
+		return PipelineStatusUnknown, err
+	}
+
+	checks, err := g.checkRuns(project, sha)
+	if err != nil {
+		return PipelineStatusUnknown, err
+	}
+
+	if combined.TotalCount == 0 && checks.TotalCount == 0 {
+		return PipelineStatusUnknown, nil
+	}
+
+	status := PipelineStatusSuccess
+	switch combined.State {
+	case "failure", "error":
+		return PipelineStatusFailed, nil
+	case "pending":
+		if combined.TotalCount > 0 {
+			status = PipelineStatusPending
+		}
+	}
+
+	for _, run := range checks.CheckRuns {
+		if run.Status != "completed" {
+			status = PipelineStatusRunning
+			continue
+		}
+		switch run.Conclusion {
+		case "failure", "timed_out", "action_required":
+			return PipelineStatusFailed, nil
+		}
+	}
+
+	return status, nil
+}
+
+// checkRuns fetches every page of the check-runs of a commit.
+// Unlike the other list endpoints, the items are wrapped in an object.
+func (g githubProjectMRChecker) checkRuns(project, sha string) (githubCheckRuns, error) {
+	var checks githubCheckRuns
+
+	next, err := g.client.endpointURL(fmt.Sprintf("repos/%s/commits/%s/check-runs", project, sha), url.Values{"per_page": {githubPerPage}})
+	if err != nil {
+		return checks, err
+	}
+
+	for next != "" {
+		req, err := g.client.newRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return checks, err
+		}
+
+		var page githubCheckRuns
+		if next, err = g.client.do(req, &page); err != nil {
+			return checks, err
+		}
+		checks.TotalCount = page.TotalCount
+		checks.CheckRuns = append(checks.CheckRuns, page.CheckRuns...)
+	}
+	return checks, nil
+}
+
+func githubLogins(users []githubUser) []string {
+	var logins []string
+	for _, u := range users {
+		logins = append(logins, u.Login)
+	}
+	return logins
+}
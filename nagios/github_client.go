@@ -0,0 +1,175 @@
+package nagios
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	githubDefaultAPIEndpoint = "https://api.github.com"
+	githubPerPage            = "100"
+)
+
+var (
+	// errStopListing can be returned by the list callback to stop walking through the pages
+	errStopListing = errors.New("stop listing")
+
+	// <https://api.github.com/repositories/1/pulls?page=2>; rel="next"
+	githubNextLinkRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+// githubClient is a minimalist client for the Github REST API v3
+type githubClient struct {
+	baseURL    *url.URL
+	apiToken   string
+	httpClient *http.Client
+}
+
+func newGithubClient(endpoint, apiToken string, httpClient *http.Client) (*githubClient, error) {
+	if endpoint == "" {
+		endpoint = githubDefaultAPIEndpoint
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/")
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing github API endpoint")
+	}
+
+	return &githubClient{
+		baseURL:    u,
+		apiToken:   apiToken,
+		httpClient: httpClient,
+	}, nil
+}
+
+// endpointURL resolves path against the API base URL
+func (c githubClient) endpointURL(path string, query url.Values) (string, error) {
+	u, err := c.baseURL.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return "", err
+	}
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+func (c githubClient) newRequest(method, rawURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiToken != "" {
+		req.Header.Set("Authorization", "token "+c.apiToken)
+	}
+	return req, nil
+}
+
+// do sends the request and decodes the JSON response body into v.
+// It returns the URL of the next page of results, if any.
+func (c githubClient) do(req *http.Request, v interface{}) (string, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return "", fmt.Errorf("%s %s: %d %s", req.Method, req.URL, resp.StatusCode, apiErr.Message)
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return "", errors.Wrapf(err, "decoding response of %s %s", req.Method, req.URL)
+		}
+	}
+
+	var next string
+	if m := githubNextLinkRegexp.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		next = m[1]
+	}
+	return next, nil
+}
+
+// get fetches a single resource
+func (c githubClient) get(path string, query url.Values, v interface{}) error {
+	u, err := c.endpointURL(path, query)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req, v)
+	return err
+}
+
+// post creates a resource from the JSON encoding of body
+func (c githubClient) post(path string, body interface{}, v interface{}) error {
+	u, err := c.endpointURL(path, nil)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "encoding request body")
+	}
+	req, err := c.newRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req, v)
+	return err
+}
+
+// list walks through every page of a list endpoint
+// and calls each for every item returned, until each returns errStopListing
+func (c githubClient) list(path string, query url.Values, each func(item json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", githubPerPage)
+
+	next, err := c.endpointURL(path, query)
+	if err != nil {
+		return err
+	}
+
+	for next != "" {
+		req, err := c.newRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+
+		var page []json.RawMessage
+		if next, err = c.do(req, &page); err != nil {
+			return err
+		}
+
+		for _, item := range page {
+			if err := each(item); err == errStopListing {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
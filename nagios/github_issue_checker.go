@@ -0,0 +1,100 @@
+package nagios
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type githubIssue struct {
+	ID        int        `json:"id"`
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	HTMLURL   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	User      githubUser `json:"user"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []githubUser `json:"assignees"`
+	Milestone *struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+	// only set on pull requests, which github considers as issues
+	PullRequest *json.RawMessage `json:"pull_request"`
+}
+
+type githubProjectIssueChecker struct {
+	client *githubClient
+}
+
+func newGithubProjectIssueChecker(endpoint, apiToken string, httpClient *http.Client) (*githubProjectIssueChecker, error) {
+	c, err := newGithubClient(endpoint, apiToken, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &githubProjectIssueChecker{
+		client: c,
+	}, nil
+}
+
+func (g githubProjectIssueChecker) CheckIssues(project string, iopts ListIssuesOptions) ([]Issue, error) {
+	var gi []Issue
+
+	query := url.Values{}
+	query.Set("state", "open")
+	if len(iopts.Labels) > 0 {
+		query.Set("labels", strings.Join(iopts.Labels, ","))
+	}
+	if iopts.Assignee != "" {
+		query.Set("assignee", iopts.Assignee)
+	}
+
+	err := g.client.list(fmt.Sprintf("repos/%s/issues", project), query, func(item json.RawMessage) error {
+		var issue githubIssue
+		if err := json.Unmarshal(item, &issue); err != nil {
+			return err
+		}
+
+		if issue.PullRequest != nil {
+			return nil
+		}
+
+		i := Issue{
+			ID:        issue.ID,
+			IID:       issue.Number,
+			Project:   project,
+			CreatedAt: issue.CreatedAt,
+			UpdatedAt: issue.UpdatedAt,
+			Title:     issue.Title,
+			WebURL:    issue.HTMLURL,
+			Author:    issue.User.Login,
+			Assignees: githubLogins(issue.Assignees),
+		}
+		for _, l := range issue.Labels {
+			i.Labels = append(i.Labels, l.Name)
+		}
+		if issue.Milestone != nil {
+			i.Milestone = issue.Milestone.Title
+		}
+
+		// the github API selects milestones by number, not by title
+		if iopts.Milestone != "" && i.Milestone != iopts.Milestone {
+			return nil
+		}
+
+		gi = append(gi, i)
+		return nil
+	})
+	if err != nil {
+		return gi, errors.Wrap(err, "listing repository issues")
+	}
+
+	return gi, nil
+}
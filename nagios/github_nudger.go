@@ -0,0 +1,59 @@
+package nagios
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Github handles the comments and labels of pull requests as the ones of issues
+type githubMergeRequestNudger struct {
+	client *githubClient
+}
+
+func newGithubMergeRequestNudger(endpoint, apiToken string, httpClient *http.Client) (*githubMergeRequestNudger, error) {
+	c, err := newGithubClient(endpoint, apiToken, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &githubMergeRequestNudger{
+		client: c,
+	}, nil
+}
+
+func (g githubMergeRequestNudger) ListComments(project string, iid int) ([]Comment, error) {
+	var comments []Comment
+
+	err := g.client.list(fmt.Sprintf("repos/%s/issues/%d/comments", project, iid), nil, func(item json.RawMessage) error {
+		var comment struct {
+			User      githubUser `json:"user"`
+			Body      string     `json:"body"`
+			CreatedAt time.Time  `json:"created_at"`
+		}
+		if err := json.Unmarshal(item, &comment); err != nil {
+			return err
+		}
+
+		comments = append(comments, Comment{
+			Author:    comment.User.Login,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+		})
+		return nil
+	})
+
+	return comments, err
+}
+
+func (g githubMergeRequestNudger) AddComment(project string, iid int, body string) error {
+	return g.client.post(fmt.Sprintf("repos/%s/issues/%d/comments", project, iid), map[string]string{
+		"body": body,
+	}, nil)
+}
+
+func (g githubMergeRequestNudger) AddLabels(project string, iid int, labels []string) error {
+	return g.client.post(fmt.Sprintf("repos/%s/issues/%d/labels", project, iid), map[string][]string{
+		"labels": labels,
+	}, nil)
+}
@@ -1,48 +1,267 @@
 package nagios
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/xanzy/go-gitlab"
 )
 
+const (
+	gitlabPerPage = 100
+)
+
 var (
-	// https://docs.gitlab.com/ee/api/merge_requests.html#list-project-merge-requests
-	// opened, closed, locked, or merged.
-	gitlabMergeRequestsOpenedState = "opened"
+	// https://docs.gitlab.com/ee/api/merge_requests.html#merge-status
+	gitlabCannotBeMergedStatus = "cannot_be_merged"
+
+	// body of the system note added when somebody approves a merge request
+	gitlabApprovedNoteBody = "approved this merge request"
 )
 
 type gitlabProjectMRChecker struct {
 	client *gitlab.Client
+	opts   mrCheckerOptions
 }
 
-func newGitlabProjectMRChecker(endpoint, apiToken string) (*gitlabProjectMRChecker, error) {
-	c, err := gitlab.NewClient(apiToken, gitlab.WithBaseURL(endpoint))
+func newGitlabProjectMRChecker(endpoint, apiToken string, httpClient *http.Client, opts mrCheckerOptions) (*gitlabProjectMRChecker, error) {
+	c, err := gitlab.NewClient(apiToken, gitlab.WithBaseURL(endpoint), gitlab.WithHTTPClient(httpClient))
 	if err != nil {
 		return nil, err
 	}
 	return &gitlabProjectMRChecker{
 		client: c,
+		opts:   opts,
 	}, nil
 }
 
 func (g gitlabProjectMRChecker) CheckMergeRequests(project string, targetBranch string) ([]MergeRequest, error) {
-	var gmr []MergeRequest
-	mr, _, err := g.client.MergeRequests.ListProjectMergeRequests(project, &gitlab.ListProjectMergeRequestsOptions{
-		State:        &gitlabMergeRequestsOpenedState,
-		TargetBranch: &targetBranch,
+	return g.ListMergeRequests(project, ListMergeRequestsOptions{
+		State:        MergeRequestStateOpened,
+		TargetBranch: targetBranch,
 	})
-	if err != nil {
-		return gmr, errors.Wrap(err, "listing project merge-requests")
+}
+
+func (g gitlabProjectMRChecker) ListMergeRequests(project string, lopts ListMergeRequestsOptions) ([]MergeRequest, error) {
+	var gmr []MergeRequest
+
+	state := gitlabMergeRequestState(lopts.State)
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+		State: &state,
+	}
+	if lopts.TargetBranch != "" {
+		opts.TargetBranch = &lopts.TargetBranch
+	}
+	if !lopts.UpdatedAfter.IsZero() {
+		opts.UpdatedAfter = &lopts.UpdatedAfter
+	}
+
+	var mr []*gitlab.MergeRequest
+	for {
+		page, resp, err := g.client.MergeRequests.ListProjectMergeRequests(project, opts)
+		if err != nil {
+			return gmr, errors.Wrap(err, "listing project merge-requests")
+		}
+		mr = append(mr, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
 	}
 
 	for _, cmr := range mr {
-		gmr = append(gmr, MergeRequest{
-			CreatedAt: *cmr.CreatedAt,
-			UpdatedAt: *cmr.UpdatedAt,
-			ID:        *&cmr.ID,
-			Title:     cmr.Title,
-		})
+		m := MergeRequest{
+			CreatedAt:    *cmr.CreatedAt,
+			UpdatedAt:    *cmr.UpdatedAt,
+			ID:           cmr.ID,
+			IID:          cmr.IID,
+			Project:      project,
+			Title:        cmr.Title,
+			WebURL:       cmr.WebURL,
+			TargetBranch: cmr.TargetBranch,
+			SourceBranch: cmr.SourceBranch,
+			Labels:       cmr.Labels,
+			Draft:        cmr.WorkInProgress,
+			State:        cmr.State,
+			HasConflicts: cmr.HasConflicts || cmr.MergeStatus == gitlabCannotBeMergedStatus,
+		}
+		if cmr.Author != nil {
+			m.Author = cmr.Author.Username
+		}
+		if cmr.MergedAt != nil {
+			m.MergedAt = *cmr.MergedAt
+		}
+		if cmr.ClosedAt != nil {
+			m.ClosedAt = *cmr.ClosedAt
+		}
+		if cmr.ClosedBy != nil {
+			m.ClosedBy = cmr.ClosedBy.Username
+		}
+		m.Assignees = gitlabUsernames(cmr.Assignees)
+		m.Reviewers = gitlabUsernames(cmr.Reviewers)
+
+		// head_pipeline is only part of the single merge request API
+		if g.opts.WithPipelineStatus {
+			fmr, _, err := g.client.MergeRequests.GetMergeRequest(project, cmr.IID, nil)
+			if err != nil {
+				return gmr, errors.Wrapf(err, "getting merge-request %d", cmr.IID)
+			}
+			if fmr.HeadPipeline != nil {
+				m.PipelineStatus = gitlabPipelineStatus(fmr.HeadPipeline.Status)
+			}
+		}
+
+		if g.opts.WithFirstReview {
+			firstReviewAt, err := g.firstReviewAt(project, m)
+			if err != nil {
+				return gmr, errors.Wrapf(err, "getting merge-request %d first review", cmr.IID)
+			}
+			m.FirstReviewAt = firstReviewAt
+		}
+
+		gmr = append(gmr, m)
 	}
 
 	return gmr, nil
 }
+
+// firstReviewAt returns the date of the first comment or approval
+// of somebody else than the merge request author
+func (g gitlabProjectMRChecker) firstReviewAt(project string, m MergeRequest) (time.Time, error) {
+	opts := &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+		OrderBy: gitlab.String("created_at"),
+		Sort:    gitlab.String("asc"),
+	}
+
+	for {
+		notes, resp, err := g.client.Notes.ListMergeRequestNotes(project, m.IID, opts)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		for _, note := range notes {
+			if note.Author.Username == m.Author || note.CreatedAt == nil {
+				continue
+			}
+			if note.System && !strings.HasPrefix(note.Body, gitlabApprovedNoteBody) {
+				continue
+			}
+			return *note.CreatedAt, nil
+		}
+
+		if resp.NextPage == 0 {
+			return time.Time{}, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g gitlabProjectMRChecker) ListGroupProjects(group string) ([]string, error) {
+	var projects []string
+
+	opts := &gitlab.ListGroupProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+		Archived:                 gitlab.Bool(false),
+		IncludeSubgroups:         gitlab.Bool(true),
+		WithMergeRequestsEnabled: gitlab.Bool(true),
+	}
+
+	for {
+		page, resp, err := g.client.Groups.ListGroupProjects(group, opts)
+		if err != nil {
+			return projects, errors.Wrap(err, "listing group projects")
+		}
+		for _, p := range page {
+			projects = append(projects, p.PathWithNamespace)
+		}
+
+		if resp.NextPage == 0 {
+			return projects, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g gitlabProjectMRChecker) ListBranches(project string) ([]Branch, error) {
+	var branches []Branch
+
+	opts := &gitlab.ListBranchesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+	}
+
+	for {
+		page, resp, err := g.client.Branches.ListBranches(project, opts)
+		if err != nil {
+			return branches, errors.Wrap(err, "listing project branches")
+		}
+		for _, b := range page {
+			branch := Branch{
+				Name:      b.Name,
+				Project:   project,
+				WebURL:    b.WebURL,
+				Protected: b.Protected,
+				Default:   b.Default,
+			}
+			if b.Commit != nil && b.Commit.CommittedDate != nil {
+				branch.LastCommitAt = *b.Commit.CommittedDate
+			}
+			branches = append(branches, branch)
+		}
+
+		if resp.NextPage == 0 {
+			return branches, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// gitlabMergeRequestState maps our merge request state to the gitlab one.
+// https://docs.gitlab.com/ee/api/merge_requests.html#list-project-merge-requests
+// opened, closed, locked, or merged.
+func gitlabMergeRequestState(state string) string {
+	if state == "" {
+		return MergeRequestStateOpened
+	}
+	return state
+}
+
+// gitlabPipelineStatus maps a gitlab pipeline status
+// to our normalized pipeline status
+func gitlabPipelineStatus(status string) string {
+	switch status {
+	case "success":
+		return PipelineStatusSuccess
+	case "failed":
+		return PipelineStatusFailed
+	case "canceled":
+		return PipelineStatusCanceled
+	case "running":
+		return PipelineStatusRunning
+	case "created", "waiting_for_resource", "preparing", "pending", "scheduled", "manual":
+		return PipelineStatusPending
+	}
+	return PipelineStatusUnknown
+}
+
+func gitlabUsernames(users []*gitlab.BasicUser) []string {
+	var usernames []string
+	for _, u := range users {
+		if u != nil {
+			usernames = append(usernames, u.Username)
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,77 @@
+package nagios
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/xanzy/go-gitlab"
+)
+
+type gitlabProjectIssueChecker struct {
+	client *gitlab.Client
+}
+
+func newGitlabProjectIssueChecker(endpoint, apiToken string, httpClient *http.Client) (*gitlabProjectIssueChecker, error) {
+	c, err := gitlab.NewClient(apiToken, gitlab.WithBaseURL(endpoint), gitlab.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	return &gitlabProjectIssueChecker{
+		client: c,
+	}, nil
+}
+
+func (g gitlabProjectIssueChecker) CheckIssues(project string, iopts ListIssuesOptions) ([]Issue, error) {
+	var gi []Issue
+
+	opts := &gitlab.ListProjectIssuesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+		State: gitlab.String("opened"),
+	}
+	if len(iopts.Labels) > 0 {
+		opts.Labels = gitlab.Labels(iopts.Labels)
+	}
+	if iopts.Milestone != "" {
+		opts.Milestone = &iopts.Milestone
+	}
+	if iopts.Assignee != "" {
+		opts.AssigneeUsername = &iopts.Assignee
+	}
+
+	for {
+		page, resp, err := g.client.Issues.ListProjectIssues(project, opts)
+		if err != nil {
+			return gi, errors.Wrap(err, "listing project issues")
+		}
+
+		for _, ci := range page {
+			i := Issue{
+				ID:        ci.ID,
+				IID:       ci.IID,
+				Project:   project,
+				CreatedAt: *ci.CreatedAt,
+				UpdatedAt: *ci.UpdatedAt,
+				Title:     ci.Title,
+				WebURL:    ci.WebURL,
+				Labels:    ci.Labels,
+			}
+			if ci.Author != nil {
+				i.Author = ci.Author.Username
+			}
+			if ci.Milestone != nil {
+				i.Milestone = ci.Milestone.Title
+			}
+			for _, a := range ci.Assignees {
+				i.Assignees = append(i.Assignees, a.Username)
+			}
+			gi = append(gi, i)
+		}
+
+		if resp.NextPage == 0 {
+			return gi, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
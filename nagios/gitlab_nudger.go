@@ -0,0 +1,70 @@
+package nagios
+
+import (
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type gitlabMergeRequestNudger struct {
+	client *gitlab.Client
+}
+
+func newGitlabMergeRequestNudger(endpoint, apiToken string, httpClient *http.Client) (*gitlabMergeRequestNudger, error) {
+	c, err := gitlab.NewClient(apiToken, gitlab.WithBaseURL(endpoint), gitlab.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	return &gitlabMergeRequestNudger{
+		client: c,
+	}, nil
+}
+
+func (g gitlabMergeRequestNudger) ListComments(project string, iid int) ([]Comment, error) {
+	var comments []Comment
+
+	opts := &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPerPage,
+		},
+		OrderBy: gitlab.String("created_at"),
+		Sort:    gitlab.String("asc"),
+	}
+
+	for {
+		notes, resp, err := g.client.Notes.ListMergeRequestNotes(project, iid, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, note := range notes {
+			if note.System || note.CreatedAt == nil {
+				continue
+			}
+			comments = append(comments, Comment{
+				Author:    note.Author.Username,
+				Body:      note.Body,
+				CreatedAt: *note.CreatedAt,
+			})
+		}
+
+		if resp.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g gitlabMergeRequestNudger) AddComment(project string, iid int, body string) error {
+	_, _, err := g.client.Notes.CreateMergeRequestNote(project, iid, &gitlab.CreateMergeRequestNoteOptions{
+		Body: &body,
+	})
+	return err
+}
+
+func (g gitlabMergeRequestNudger) AddLabels(project string, iid int, labels []string) error {
+	_, _, err := g.client.MergeRequests.UpdateMergeRequest(project, iid, &gitlab.UpdateMergeRequestOptions{
+		AddLabels: gitlab.Labels(labels),
+	})
+	return err
+}
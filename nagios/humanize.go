@@ -0,0 +1,81 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+const (
+	// LocaleEnglish words the durations "21m", "3d 4h", "2 weeks"
+	LocaleEnglish = "en"
+	// LocaleFrench words the durations "21 min", "3 j 4 h", "2 semaines"
+	LocaleFrench = "fr"
+)
+
+// Locales lists the languages the durations of the messages can be worded in
+var Locales = []string{LocaleEnglish, LocaleFrench}
+
+// durationWording holds the units of a locale, and whether they are
+// separated from the number
+type durationWording struct {
+	second, minute, hour, day, weeks string
+	separator                        string
+}
+
+var durationWordings = map[string]durationWording{
+	LocaleEnglish: {second: "s", minute: "m", hour: "h", day: "d", weeks: "weeks"},
+	LocaleFrench:  {second: "s", minute: "min", hour: "h", day: "j", weeks: "semaines", separator: " "},
+}
+
+func checkLocale(locale string) error {
+	if _, ok := durationWordings[locale]; !ok {
+		return fmt.Errorf("unsupported locale %q (expected one of %s)", locale, strings.Join(Locales, ","))
+	}
+	return nil
+}
+
+// HumanizeDuration formats d with its two most significant units, e.g. "42s",
+// "21m", "5h 3m", "3d 4h", and in weeks and days from two weeks on.
+// Unknown locales fall back to English.
+func HumanizeDuration(d time.Duration, locale string) string {
+	w, ok := durationWordings[locale]
+	if !ok {
+		w = durationWordings[LocaleEnglish]
+	}
+	if d < 0 {
+		d = -d
+	}
+
+	unit := func(n time.Duration, unit string) string {
+		return fmt.Sprintf("%d%s%s", n, w.separator, unit)
+	}
+	twoUnits := func(major time.Duration, majorUnit string, minor time.Duration, minorUnit string) string {
+		s := unit(d/major, majorUnit)
+		if rest := (d % major) / minor; rest > 0 {
+			s += " " + unit(rest, minorUnit)
+		}
+		return s
+	}
+
+	switch {
+	case d < time.Minute:
+		return unit(d/time.Second, w.second)
+	case d < time.Hour:
+		return unit(d/time.Minute, w.minute)
+	case d < day:
+		return twoUnits(time.Hour, w.hour, time.Minute, w.minute)
+	case d < 2*week:
+		return twoUnits(day, w.day, time.Hour, w.hour)
+	}
+	s := fmt.Sprintf("%d %s", d/week, w.weeks)
+	if rest := (d % week) / day; rest > 0 {
+		s += " " + unit(rest, w.day)
+	}
+	return s
+}
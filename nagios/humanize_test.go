@@ -0,0 +1,42 @@
+package nagios
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		d       time.Duration
+		english string
+		french  string
+	}{
+		{d: 0, english: "0s", french: "0 s"},
+		{d: 59 * time.Second, english: "59s", french: "59 s"},
+		{d: 21 * time.Minute, english: "21m", french: "21 min"},
+		{d: 21*time.Minute + 42*time.Second, english: "21m", french: "21 min"},
+		{d: time.Hour, english: "1h", french: "1 h"},
+		{d: 5*time.Hour + 3*time.Minute, english: "5h 3m", french: "5 h 3 min"},
+		{d: 3*day + 4*time.Hour, english: "3d 4h", french: "3 j 4 h"},
+		{d: 7 * day, english: "7d", french: "7 j"},
+		{d: 13*day + 23*time.Hour + 59*time.Minute, english: "13d 23h", french: "13 j 23 h"},
+		{d: 14 * day, english: "2 weeks", french: "2 semaines"},
+		{d: 15*day + 6*time.Hour, english: "2 weeks 1d", french: "2 semaines 1 j"},
+		// the sign is dropped
+		{d: -(3*day + 4*time.Hour), english: "3d 4h", french: "3 j 4 h"},
+	}
+
+	for _, tt := range tests {
+		if got := HumanizeDuration(tt.d, LocaleEnglish); got != tt.english {
+			t.Errorf("HumanizeDuration(%s, %q) = %q, want %q", tt.d, LocaleEnglish, got, tt.english)
+		}
+		if got := HumanizeDuration(tt.d, LocaleFrench); got != tt.french {
+			t.Errorf("HumanizeDuration(%s, %q) = %q, want %q", tt.d, LocaleFrench, got, tt.french)
+		}
+	}
+
+	// unknown locales fall back to English
+	if got := HumanizeDuration(2*week, "de"); got != "2 weeks" {
+		t.Errorf("HumanizeDuration(2 weeks, \"de\") = %q, want %q", got, "2 weeks")
+	}
+}
@@ -0,0 +1,17 @@
+package nagios
+
+import "time"
+
+type Issue struct {
+	ID        int
+	IID       int
+	Project   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Title     string
+	WebURL    string
+	Author    string
+	Labels    []string
+	Assignees []string
+	Milestone string // empty without milestone
+}
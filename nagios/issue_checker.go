@@ -0,0 +1,27 @@
+package nagios
+
+import "fmt"
+
+type GitIssueChecker interface {
+	// CheckIssues returns the opened issues of the project matching opts
+	CheckIssues(project string, opts ListIssuesOptions) ([]Issue, error)
+}
+
+// ListIssuesOptions selects the issues to check
+type ListIssuesOptions struct {
+	Labels    []string // issues must carry every label
+	Milestone string   // milestone title, empty for any milestone
+	Assignee  string   // username, empty for any assignee
+}
+
+// newGitIssueChecker returns the GitIssueChecker implementation
+// matching the configured git provider
+func newGitIssueChecker(cfg ProbeConfig) (GitIssueChecker, error) {
+	switch cfg.GitProvider {
+	case GitlabGitProvider:
+		return newGitlabProjectIssueChecker(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient())
+	case GithubGitProvider:
+		return newGithubProjectIssueChecker(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient())
+	}
+	return nil, fmt.Errorf("git provider %s is not supported yet", cfg.GitProvider)
+}
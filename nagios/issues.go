@@ -0,0 +1,58 @@
+package nagios
+
+import (
+	"time"
+
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkIssues alerts on the opened issues without activity for too long
+func (c nagiosProbe) checkIssues(issueChecker GitIssueChecker) {
+	opts := ListIssuesOptions{
+		Labels:    c.cfg.IssueLabels,
+		Milestone: c.cfg.IssueMilestone,
+		Assignee:  c.cfg.IssueAssignee,
+	}
+
+	issues, err := issueChecker.CheckIssues(c.cfg.Project, opts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"project":      c.cfg.Project,
+			"api-endpoint": c.cfg.APIEndpoint,
+		}).Error("fail to check for issues")
+		c.nagCheck.Criticalf("fail to check for issues of %s: %s", c.cfg.Project, err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"issues": issues,
+	}).Debug("issues fetched successfully")
+
+	c.addTotalDurationPerfDatum()
+
+	c.checkMetrics(metricInput{issues: issues}, OpenedIssuesMetric, OldestIssueMetric)
+
+	// the service state only comes from the metrics selected with --check-metric
+	if len(c.checkedMetrics) > 0 {
+		c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "All checked metrics within thresholds"))
+		return
+	}
+
+	if len(issues) == 0 {
+		c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "No opened issues"))
+		return
+	}
+
+	c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "No issues too old"))
+
+	for _, issue := range issues {
+		tSinceLastUpdate := time.Since(issue.UpdatedAt)
+		if tSinceLastUpdate >= c.cfg.CriticalLastUpdateDelay {
+			c.nagCheck.AddResultf(nagiosplugin.CRITICAL, localize(c.cfg.Locale, "Issue %d last activity was %s ago"), issue.IID, HumanizeDuration(tSinceLastUpdate, c.cfg.Locale))
+		} else if tSinceLastUpdate >= c.cfg.WarningLastUpdateDelay {
+			c.nagCheck.AddResultf(nagiosplugin.WARNING, localize(c.cfg.Locale, "Issue %d last activity was %s ago"), issue.IID, HumanizeDuration(tSinceLastUpdate, c.cfg.Locale))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package nagios
+
+import (
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListMergeRequests returns the opened merge requests a check would evaluate:
+// the ones of the configured project, projects or group that match the filter
+// and are not ignored by a rule
+func ListMergeRequests(cfg ProbeConfig) ([]MergeRequest, error) {
+	if err := checkLocale(cfg.Locale); err != nil {
+		return nil, err
+	}
+
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.initSelection(); err != nil {
+		return nil, err
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, probe.mrCheckerOptions())
+	if err != nil {
+		return nil, errors.Wrapf(err, "initializing %s checker", cfg.GitProvider)
+	}
+
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	var mr []MergeRequest
+	for _, project := range projects {
+		pmr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+			State:        MergeRequestStateOpened,
+			TargetBranch: cfg.TargetBranch,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing merge requests of %s", project)
+		}
+		mr = append(mr, pmr...)
+	}
+
+	selected, _ := probe.selectMergeRequests(mr)
+	return selected, nil
+}
+
+// resolveProjects returns the projects of the configured group,
+// the configured list of projects, or the configured project
+func resolveProjects(cfg ProbeConfig, mrChecker GitMergeRequestChecker) ([]string, error) {
+	switch {
+	case cfg.Group != "":
+		projects, err := mrChecker.ListGroupProjects(cfg.Group)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing projects of group %s", cfg.Group)
+		}
+		log.WithFields(log.Fields{
+			"group":    cfg.Group,
+			"projects": projects,
+		}).Debug("group projects listed successfully")
+		return projects, nil
+	case len(cfg.Projects) > 0:
+		return cfg.Projects, nil
+	case cfg.Project != "":
+		return []string{cfg.Project}, nil
+	}
+	return nil, errors.New("a project, a list of projects or a group is required")
+}
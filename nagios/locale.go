@@ -0,0 +1,77 @@
+package nagios
+
+// messageCatalogs translates the built-in messages and templates of the checks,
+// keyed by their English wording. The English wording is used when a
+// locale has no translation for a message.
+var messageCatalogs = map[string]map[string]string{
+	LocaleFrench: {
+		// merge-requests
+		"All checked metrics within thresholds":     "Toutes les métriques vérifiées sont dans les seuils",
+		"No opened merge requests":                  "Aucune demande de fusion ouverte",
+		"No merge requests too old":                 "Aucune demande de fusion trop ancienne",
+		"Merge request %d last activity was %s ago": "Dernière activité de la demande de fusion %d il y a %s",
+		"last activity was %s ago":                  "dernière activité il y a %s",
+		"has conflicts":                             "a des conflits",
+		"has a failed pipeline":                     "a un pipeline en échec",
+		"have conflicts":                            "ont des conflits",
+		"have a failed pipeline":                    "ont un pipeline en échec",
+		"%d merge requests %s":                      "%d demandes de fusion %s",
+		"Merge requests that %s: %s":                "Demandes de fusion qui %s : %s",
+		"%s is %s":                                  "%s vaut %s",
+		"Merge requests backlog within thresholds":  "Nombre de demandes de fusion dans les seuils",
+		"%s is %s of %d merge requests":             "%s est %s de %d demandes de fusion",
+		"Merge requests per %s:\n%s":                "Demandes de fusion par %s :\n%s",
+		AssigneeRole:                                "assigné",
+		ReviewerRole:                                "relecteur",
+		"No overloaded %s across %d projects":       "Aucun %s surchargé sur %d projets",
+		"%d overloaded %ss across %d projects":      "%d %ss surchargés sur %d projets",
+		"Overloaded %ss:\n%s":                       "Les %ss surchargés :\n%s",
+		"Merge request %d merged after %s":          "Demande de fusion %d fusionnée après %s",
+		"No merge requests merged in the last %s":   "Aucune demande de fusion fusionnée sur une période de %s",
+		"a stale rule":                              "une règle d'inactivité",
+		"Merge request %d closed by %s: %s":         "Demande de fusion %d fermée par %s : %s",
+		"%d merge requests merged in the last %s, median time to merge %s":    "%d demandes de fusion fusionnées sur une période de %s, durée médiane avant fusion %s",
+		"%d merge requests closed without merge and %d merged in the last %s": "%d demandes de fusion fermées sans fusion et %d fusionnées sur une période de %s",
+		"*%s*: %d merge requests of %s need attention":                        "*%s* : %d demandes de fusion de %s demandent de l'attention",
+		"**%s**: %d merge requests of %s need attention":                      "**%s** : %d demandes de fusion de %s demandent de l'attention",
+		"• <%s|%s> by %s":                       "• <%s|%s> par %s",
+		"- [%s](%s) by %s":                      "- [%s](%s) par %s",
+		", last activity %s ago, opened %s ago": ", dernière activité il y a %s, ouverte il y a %s",
+
+		// branches and issues
+		"No stale branches":                 "Aucune branche inactive",
+		"Branch %s last commit was %s ago":  "Dernier commit de la branche %s il y a %s",
+		"No opened issues":                  "Aucun ticket ouvert",
+		"No issues too old":                 "Aucun ticket trop ancien",
+		"Issue %d last activity was %s ago": "Dernière activité du ticket %d il y a %s",
+
+		// default templates
+		DefaultNudgeTemplate:         "@{{ .Author }} cette demande de fusion n'a pas eu d'activité depuis {{ humanize .LastActivity }}. Est-elle toujours d'actualité ?",
+		DefaultDigestSubjectTemplate: `{{ len .MergeRequests }} demandes de fusion vous attendent`,
+		DefaultDigestTextTemplate: `Bonjour {{ .User }},
+
+Ces demandes de fusion {{ if eq .Role "author" }}que vous avez ouvertes{{ else }}qui vous sont assignées{{ end }} n'ont pas eu d'activité depuis un moment :
+{{ range .MergeRequests }}
+- {{ .Title }} ({{ .Project }})
+  {{ .WebURL }}
+  dernière activité il y a {{ humanize .LastActivity }}, ouverte il y a {{ humanize .Age }}
+{{ end }}`,
+		DefaultDigestHTMLTemplate: `<p>Bonjour {{ .User }},</p>
+<p>Ces demandes de fusion {{ if eq .Role "author" }}que vous avez ouvertes{{ else }}qui vous sont assignées{{ end }} n'ont pas eu d'activité depuis un moment :</p>
+<ul>
+{{- range .MergeRequests }}
+  <li><a href="{{ .WebURL }}">{{ .Title }}</a> ({{ .Project }}) : dernière activité il y a {{ humanize .LastActivity }}, ouverte il y a {{ humanize .Age }}</li>
+{{- end }}
+</ul>
+`,
+	},
+}
+
+// localize returns the translation of message in locale,
+// message itself if it has none
+func localize(locale, message string) string {
+	if translated, ok := messageCatalogs[locale][message]; ok {
+		return translated
+	}
+	return message
+}
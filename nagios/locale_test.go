@@ -0,0 +1,82 @@
+package nagios
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestMessageCatalogs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	templates := map[string]bool{
+		DefaultNudgeTemplate:         true,
+		DefaultDigestSubjectTemplate: true,
+		DefaultDigestTextTemplate:    true,
+		DefaultDigestHTMLTemplate:    true,
+	}
+
+	for locale, catalog := range messageCatalogs {
+		if err := checkLocale(locale); err != nil {
+			t.Errorf("catalog of an unsupported locale: %s", err)
+		}
+		for message, translated := range catalog {
+			// the arguments are passed in the order of the English message
+			if got, want := verbs.FindAllString(translated, -1), verbs.FindAllString(message, -1); strings.Join(got, "") != strings.Join(want, "") {
+				t.Errorf("%s: %q has the verbs %v, want %v", locale, translated, got, want)
+			}
+			if templates[message] {
+				if _, err := template.New(message).Funcs(templateFuncs(locale)).Parse(translated); err != nil {
+					t.Errorf("%s: %s", locale, err)
+				}
+			}
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		locale, message, want string
+	}{
+		{LocaleEnglish, "No opened merge requests", "No opened merge requests"},
+		{LocaleFrench, "No opened merge requests", "Aucune demande de fusion ouverte"},
+		{LocaleFrench, ReviewerRole, "relecteur"},
+		// custom templates are not translated
+		{LocaleFrench, "{{ .Title }}", "{{ .Title }}"},
+		{"de", "No opened merge requests", "No opened merge requests"},
+	}
+
+	for _, tt := range tests {
+		if got := localize(tt.locale, tt.message); got != tt.want {
+			t.Errorf("localize(%q, %q) = %q, want %q", tt.locale, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestFrenchDigestTemplates(t *testing.T) {
+	templates, err := parseDigestTemplates(ProbeConfig{Locale: LocaleFrench})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	subject, text, html, err := templates.execute(newDigestTemplateData(Digest{
+		User:          "alice",
+		Role:          DigestGroupByAssignee,
+		MergeRequests: []MergeRequest{sampleMergeRequest(now)},
+	}, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject != "1 demandes de fusion vous attendent" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, body := range []string{text, html} {
+		for _, want := range []string{"Bonjour alice", "qui vous sont assignées", "dernière activité il y a 1 j, ouverte il y a 2 j"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body %q does not contain %q", body, want)
+			}
+		}
+	}
+}
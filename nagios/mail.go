@@ -0,0 +1,319 @@
+package nagios
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultDigestSubjectTemplate is the subject of the digest emails
+	DefaultDigestSubjectTemplate = `{{ len .MergeRequests }} merge requests waiting for you`
+
+	// DefaultDigestTextTemplate is the plain text body of the digest emails
+	DefaultDigestTextTemplate = `Hello {{ .User }},
+
+These merge requests {{ if eq .Role "author" }}you opened{{ else }}assigned to you{{ end }} have had no activity for a while:
+{{ range .MergeRequests }}
+- {{ .Title }} ({{ .Project }})
+  {{ .WebURL }}
+  last activity {{ humanize .LastActivity }} ago, opened {{ humanize .Age }} ago
+{{ end }}`
+
+	// DefaultDigestHTMLTemplate is the HTML body of the digest emails
+	DefaultDigestHTMLTemplate = `<p>Hello {{ .User }},</p>
+<p>These merge requests {{ if eq .Role "author" }}you opened{{ else }}assigned to you{{ end }} have had no activity for a while:</p>
+<ul>
+{{- range .MergeRequests }}
+  <li><a href="{{ .WebURL }}">{{ .Title }}</a> ({{ .Project }}): last activity {{ humanize .LastActivity }} ago, opened {{ humanize .Age }} ago</li>
+{{- end }}
+</ul>
+`
+)
+
+// DigestTemplateData is what the digest templates are executed with
+type DigestTemplateData struct {
+	User          string
+	Email         string
+	Role          string
+	MergeRequests []MergeRequestTemplateData
+}
+
+func newDigestTemplateData(d Digest, now time.Time) DigestTemplateData {
+	data := DigestTemplateData{
+		User:  d.User,
+		Email: d.Email,
+		Role:  d.Role,
+	}
+	for _, m := range d.MergeRequests {
+		data.MergeRequests = append(data.MergeRequests, newMergeRequestTemplateData(m, now))
+	}
+	return data
+}
+
+type digestTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// parseDigestTemplates parses the configured templates, or the default ones,
+// and checks that they render
+func parseDigestTemplates(cfg ProbeConfig) (digestTemplates, error) {
+	var t digestTemplates
+	var err error
+
+	if err := checkLocale(cfg.Locale); err != nil {
+		return t, err
+	}
+
+	text := func(configured, fallback string) string {
+		if configured == "" {
+			return localize(cfg.Locale, fallback)
+		}
+		return configured
+	}
+
+	if t.subject, err = texttemplate.New("digest subject").Funcs(templateFuncs(cfg.Locale)).Option("missingkey=error").Parse(text(cfg.ReportSubjectTemplate, DefaultDigestSubjectTemplate)); err != nil {
+		return t, errors.Wrap(err, "parsing digest subject template")
+	}
+	if t.text, err = texttemplate.New("digest text").Funcs(templateFuncs(cfg.Locale)).Option("missingkey=error").Parse(text(cfg.ReportTextTemplate, DefaultDigestTextTemplate)); err != nil {
+		return t, errors.Wrap(err, "parsing digest text template")
+	}
+	if t.html, err = htmltemplate.New("digest html").Funcs(htmltemplate.FuncMap(templateFuncs(cfg.Locale))).Option("missingkey=error").Parse(text(cfg.ReportHTMLTemplate, DefaultDigestHTMLTemplate)); err != nil {
+		return t, errors.Wrap(err, "parsing digest html template")
+	}
+
+	now := time.Now()
+	sample := Digest{
+		User:          "author",
+		Email:         "author@example.com",
+		Role:          DigestGroupByAuthor,
+		MergeRequests: []MergeRequest{sampleMergeRequest(now)},
+	}
+	if _, _, _, err := t.execute(newDigestTemplateData(sample, now)); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// execute renders the subject, the text body and the HTML body
+func (t digestTemplates) execute(data DigestTemplateData) (string, string, string, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", "", errors.Wrapf(err, "executing %s template", t.subject.Name())
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return "", "", "", errors.Wrapf(err, "executing %s template", t.text.Name())
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return "", "", "", errors.Wrapf(err, "executing %s template", t.html.Name())
+	}
+	// a subject spans a single line
+	return strings.Join(strings.Fields(subject.String()), " "), text.String(), html.String(), nil
+}
+
+// message renders the digest d as a multipart/alternative email
+func (t digestTemplates) message(from *mail.Address, d Digest, now time.Time) ([]byte, error) {
+	subject, text, html, err := t.execute(newDigestTemplateData(d, now))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	to := mail.Address{Name: d.User, Address: d.Email}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", &to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%d.%s@%s>\r\n", now.UnixNano(), d.User, from.Address[strings.LastIndex(from.Address, "@")+1:])
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := io.WriteString(qp, part.body); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DigestDelivery is the outcome of a digest
+type DigestDelivery struct {
+	Digest
+	Skipped string // why the digest was not sent, empty if it was
+	Err     error
+}
+
+// SendDigests sends a digest email to every author or assignee of stale merge
+// requests, or writes the emails to preview with ReportToStdout.
+// Users without email address or outside the allowed domains are skipped.
+func SendDigests(cfg ProbeConfig, preview io.Writer) ([]DigestDelivery, error) {
+	templates, err := parseDigestTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SMTPFrom == "" {
+		return nil, errors.New("a sender address is required")
+	}
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing sender address %q", cfg.SMTPFrom)
+	}
+
+	mailer := smtpMailer{
+		address:  cfg.SMTPAddress,
+		user:     cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+		startTLS: cfg.SMTPStartTLS,
+		timeout:  cfg.Timeout,
+	}
+	if !cfg.ReportToStdout {
+		if _, _, err := net.SplitHostPort(cfg.SMTPAddress); err != nil {
+			return nil, errors.Wrap(err, "parsing SMTP address")
+		}
+	}
+
+	digests, err := buildDigests(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []DigestDelivery
+	now := time.Now()
+	for _, d := range digests {
+		delivery := DigestDelivery{Digest: d}
+		switch {
+		case d.Email == "":
+			delivery.Skipped = "no email address"
+		case !isAllowedRecipient(cfg.ReportAllowedDomains, d.Email):
+			delivery.Skipped = "domain not allowed"
+		}
+		if delivery.Skipped != "" {
+			deliveries = append(deliveries, delivery)
+			continue
+		}
+
+		msg, err := templates.message(from, d, now)
+		if err != nil {
+			delivery.Err = err
+			deliveries = append(deliveries, delivery)
+			continue
+		}
+
+		if cfg.ReportToStdout {
+			fmt.Fprintf(preview, "%s\n", strings.ReplaceAll(string(msg), "\r\n", "\n"))
+		} else if delivery.Err = mailer.send(from.Address, d.Email, msg); delivery.Err == nil {
+			log.WithFields(log.Fields{
+				"user":           d.User,
+				"email":          d.Email,
+				"merge-requests": len(d.MergeRequests),
+			}).Debug("digest sent successfully")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// smtpMailer sends emails through an SMTP relay
+type smtpMailer struct {
+	address  string
+	user     string
+	password string
+	startTLS bool
+	timeout  time.Duration
+}
+
+func (s smtpMailer) send(from, to string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.address)
+	if err != nil {
+		return errors.Wrap(err, "parsing SMTP address")
+	}
+
+	conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", s.address)
+	}
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrapf(err, "connecting to %s", s.address)
+	}
+	defer c.Close()
+
+	if s.startTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", s.address)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return errors.Wrap(err, "starting TLS")
+		}
+	}
+
+	// smtp.PlainAuth refuses to send the password unencrypted, but to localhost
+	if s.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.password, host)); err != nil {
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return errors.Wrapf(err, "sending MAIL FROM:<%s>", from)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return errors.Wrapf(err, "sending RCPT TO:<%s>", to)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "sending DATA")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "sending message")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "sending message")
+	}
+	return c.Quit()
+}
@@ -0,0 +1,270 @@
+package nagios
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is an SMTP relay on 127.0.0.1 recording the envelopes and
+// messages it receives
+type smtpStandIn struct {
+	listener net.Listener
+	startTLS bool // whether STARTTLS is offered
+
+	mu          sync.Mutex
+	connections int
+	commands    []string // MAIL and RCPT commands
+	messages    [][]byte
+}
+
+func newSMTPStandIn(t *testing.T, startTLS bool) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{listener: l, startTLS: startTLS}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		if i := strings.Index(verb, ":"); i >= 0 {
+			verb = verb[:i]
+		}
+
+		switch verb {
+		case "EHLO":
+			if s.startTLS {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 STARTTLS")
+			} else {
+				tp.PrintfLine("250 localhost")
+			}
+		case "MAIL", "RCPT":
+			s.mu.Lock()
+			s.commands = append(s.commands, line)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			msg, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	templates, err := parseDigestTemplates(ProbeConfig{Locale: LocaleEnglish})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	from := &mail.Address{Name: "Merge requests", Address: "nagios@example.com"}
+	msg, err := templates.message(from, Digest{
+		User:          "alice",
+		Email:         "alice@example.com",
+		Role:          DigestGroupByAuthor,
+		MergeRequests: []MergeRequest{sampleMergeRequest(now)},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := newSMTPStandIn(t, false)
+	mailer := smtpMailer{address: relay.addr(), timeout: 5 * time.Second}
+	if err := mailer.send(from.Address, "alice@example.com", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	wantCommands := []string{"MAIL FROM:<nagios@example.com>", "RCPT TO:<alice@example.com>"}
+	if strings.Join(relay.commands, "\n") != strings.Join(wantCommands, "\n") {
+		t.Errorf("commands = %q, want %q", relay.commands, wantCommands)
+	}
+	if len(relay.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(relay.messages))
+	}
+
+	m, err := mail.ReadMessage(bytes.NewReader(relay.messages[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Header.Get("To"); got != `"alice" <alice@example.com>` {
+		t.Errorf("To = %q", got)
+	}
+	if got := m.Header.Get("Subject"); got != "1 merge requests waiting for you" {
+		t.Errorf("Subject = %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", m.Header.Get("Content-Type"))
+	}
+
+	var parts []string
+	r := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(p)
+		parts = append(parts, p.Header.Get("Content-Type"))
+		for _, want := range []string{"Sample merge request", "last activity 1d ago, opened 2d ago"} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("%s part %q does not contain %q", p.Header.Get("Content-Type"), body, want)
+			}
+		}
+	}
+	wantParts := []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}
+	if strings.Join(parts, ",") != strings.Join(wantParts, ",") {
+		t.Errorf("parts = %q, want %q", parts, wantParts)
+	}
+}
+
+func TestSMTPMailerStartTLSNotOffered(t *testing.T) {
+	relay := newSMTPStandIn(t, false)
+	mailer := smtpMailer{address: relay.addr(), startTLS: true, timeout: 5 * time.Second}
+
+	err := mailer.send("nagios@example.com", "alice@example.com", []byte("Subject: test\r\n\r\ntest\r\n"))
+	want := relay.addr() + " does not support STARTTLS"
+	if err == nil || err.Error() != want {
+		t.Errorf("send() error = %v, want %q", err, want)
+	}
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if len(relay.commands) != 0 {
+		t.Errorf("commands %q sent without TLS", relay.commands)
+	}
+}
+
+func TestIsAllowedRecipient(t *testing.T) {
+	tests := []struct {
+		domains []string
+		email   string
+		want    bool
+	}{
+		{nil, "alice@example.com", true},
+		{nil, "alice", true},
+		{[]string{"example.com"}, "alice@example.com", true},
+		{[]string{"example.com"}, "alice@EXAMPLE.com", true},
+		{[]string{"example.org", "example.com"}, "alice@example.com", true},
+		{[]string{"example.com"}, "alice@example.org", false},
+		{[]string{"example.com"}, "alice@sub.example.com", false},
+		{[]string{"example.com"}, "alice@example.com@evil.org", false},
+		{[]string{"example.com"}, "alice", false},
+	}
+
+	for _, tt := range tests {
+		if got := isAllowedRecipient(tt.domains, tt.email); got != tt.want {
+			t.Errorf("isAllowedRecipient(%q, %q) = %v, want %v", tt.domains, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestSendDigestsToStdout(t *testing.T) {
+	updated := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v4/":
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+		case strings.HasSuffix(r.URL.Path, "/merge_requests"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"id":1001,"iid":1,"title":"Stale merge request","state":"opened",` +
+				`"created_at":"` + updated + `","updated_at":"` + updated + `",` +
+				`"web_url":"https://gitlab.example.com/group/project/-/merge_requests/1",` +
+				`"target_branch":"master","author":{"username":"alice"}}]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	relay := newSMTPStandIn(t, false)
+	cfg := ProbeConfig{
+		APIEndpoint:             srv.URL,
+		GitProvider:             GitlabGitProvider,
+		Project:                 "group/project",
+		Timeout:                 5 * time.Second,
+		WarningLastUpdateDelay:  6 * time.Hour,
+		CriticalLastUpdateDelay: 24 * time.Hour,
+		Locale:                  LocaleEnglish,
+		SMTPAddress:             relay.addr(),
+		SMTPFrom:                "nagios@example.com",
+		ReportGroupBy:           DigestGroupByAuthor,
+		ReportEmailDomain:       "example.com",
+		ReportToStdout:          true,
+	}
+
+	var preview bytes.Buffer
+	deliveries, err := SendDigests(cfg, &preview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Err != nil || deliveries[0].Skipped != "" || deliveries[0].Email != "alice@example.com" {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+	for _, want := range []string{"To: \"alice\" <alice@example.com>\n", "Content-Type: multipart/alternative", "Stale merge request"} {
+		if !strings.Contains(preview.String(), want) {
+			t.Errorf("preview %q does not contain %q", preview.String(), want)
+		}
+	}
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if relay.connections != 0 {
+		t.Errorf("%d connections to the SMTP relay, want none", relay.connections)
+	}
+}
@@ -2,9 +2,74 @@ package nagios
 
 import "time"
 
+const (
+	// Normalized head pipeline status, whatever the git provider
+	PipelineStatusUnknown  = ""
+	PipelineStatusPending  = "pending"
+	PipelineStatusRunning  = "running"
+	PipelineStatusSuccess  = "success"
+	PipelineStatusFailed   = "failed"
+	PipelineStatusCanceled = "canceled"
+)
+
+const (
+	MergeRequestStateOpened = "opened"
+	MergeRequestStateMerged = "merged"
+	MergeRequestStateClosed = "closed"
+)
+
 type MergeRequest struct {
-	ID        int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Title     string
+	ID             int
+	IID            int
+	Project        string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Title          string
+	WebURL         string
+	TargetBranch   string
+	SourceBranch   string
+	Author         string
+	Labels         []string
+	Assignees      []string
+	Reviewers      []string
+	Draft          bool
+	State          string
+	MergedAt       time.Time // zero unless merged
+	ClosedAt       time.Time // zero unless closed
+	ClosedBy       string    // empty if unknown or not closed
+	FirstReviewAt  time.Time // zero if unknown or not reviewed yet
+	HasConflicts   bool
+	PipelineStatus string
+}
+
+// HasFailedPipeline returns true if the merge request
+// head pipeline is known to have failed
+func (m MergeRequest) HasFailedPipeline() bool {
+	return m.PipelineStatus == PipelineStatusFailed
+}
+
+// HasLabel returns true if the merge request carries label
+func (m MergeRequest) HasLabel(label string) bool {
+	for _, l := range m.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeToMerge returns the time elapsed between the creation
+// of the merge request and its merge
+func (m MergeRequest) TimeToMerge() time.Duration {
+	return m.MergedAt.Sub(m.CreatedAt)
+}
+
+// TimeToFirstReview returns the time elapsed between the creation of the merge
+// request and its first review. It returns false if the merge request has not
+// been reviewed or if the review time is unknown.
+func (m MergeRequest) TimeToFirstReview() (time.Duration, bool) {
+	if m.FirstReviewAt.IsZero() {
+		return 0, false
+	}
+	return m.FirstReviewAt.Sub(m.CreatedAt), true
 }
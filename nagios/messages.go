@@ -0,0 +1,109 @@
+package nagios
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CheckTemplateData is what the merge-requests mode summary
+// and long output templates are executed with
+type CheckTemplateData struct {
+	Project       string
+	MergeRequests []EvaluatedMergeRequestTemplateData
+}
+
+// EvaluatedMergeRequestTemplateData is a merge request evaluated by the check
+type EvaluatedMergeRequestTemplateData struct {
+	MergeRequestTemplateData
+	Status  string   // individual status of the merge request
+	Reasons []string // explains a Status other than OK
+}
+
+// messageTemplates override the messages of the merge-requests mode,
+// nil templates keep the built-in messages
+type messageTemplates struct {
+	okSummary  *template.Template
+	problem    *template.Template
+	longOutput *template.Template
+}
+
+// parseMessageTemplates parses the configured templates and checks that they render
+func parseMessageTemplates(cfg ProbeConfig) (messageTemplates, error) {
+	var t messageTemplates
+
+	parse := func(name, text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		t, err := template.New(name).Funcs(templateFuncs(cfg.Locale)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s template", name)
+		}
+		return t, nil
+	}
+
+	var err error
+	if t.okSummary, err = parse("ok summary", cfg.OKSummaryTemplate); err != nil {
+		return t, err
+	}
+	if t.problem, err = parse("problem", cfg.ProblemTemplate); err != nil {
+		return t, err
+	}
+	if t.longOutput, err = parse("long output", cfg.LongOutputTemplate); err != nil {
+		return t, err
+	}
+
+	// unknown fields are only reported on execution
+	now := time.Now()
+	sample := newEvaluatedMergeRequestTemplateData(sampleMergeRequest(now), "WARNING", []string{"last activity was 1d ago"}, now)
+	data := CheckTemplateData{Project: "group/project", MergeRequests: []EvaluatedMergeRequestTemplateData{sample}}
+	if _, err := executeMessageTemplate(t.okSummary, data, true); err != nil {
+		return t, err
+	}
+	// the summary of a project without opened merge requests
+	if _, err := executeMessageTemplate(t.okSummary, CheckTemplateData{Project: data.Project}, true); err != nil {
+		return t, err
+	}
+	if _, err := executeMessageTemplate(t.problem, sample, true); err != nil {
+		return t, err
+	}
+	if _, err := executeMessageTemplate(t.longOutput, data, false); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func newEvaluatedMergeRequestTemplateData(m MergeRequest, status string, reasons []string, now time.Time) EvaluatedMergeRequestTemplateData {
+	return EvaluatedMergeRequestTemplateData{
+		MergeRequestTemplateData: newMergeRequestTemplateData(m, now),
+		Status:                   status,
+		Reasons:                  reasons,
+	}
+}
+
+// executeMessageTemplate renders t, which may be nil. Summaries and problem
+// lines are required to render a single non empty line.
+func executeMessageTemplate(t *template.Template, data interface{}, line bool) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "executing %s template", t.Name())
+	}
+	if !line {
+		return strings.TrimRight(buf.String(), "\n"), nil
+	}
+
+	message := strings.TrimSpace(strings.ReplaceAll(buf.String(), "\n", " "))
+	if message == "" {
+		return "", fmt.Errorf("%s template rendered an empty message", t.Name())
+	}
+	return message, nil
+}
@@ -0,0 +1,98 @@
+package nagios
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessageTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProbeConfig
+		wantErr string
+	}{
+		{
+			name: "built-in messages",
+		},
+		{
+			name: "valid templates",
+			cfg: ProbeConfig{
+				OKSummaryTemplate:  "{{ len .MergeRequests }} merge requests of {{ .Project }} are fine",
+				ProblemTemplate:    "!{{ .IID }} {{ .Title | truncate 10 }} idle for {{ humanize .LastActivity }}",
+				LongOutputTemplate: "{{ range .MergeRequests }}{{ .Status }} !{{ .IID }}\n{{ end }}",
+			},
+		},
+		{
+			name:    "syntax error",
+			cfg:     ProbeConfig{ProblemTemplate: "{{ .IID "},
+			wantErr: "parsing problem template",
+		},
+		{
+			name:    "unknown field",
+			cfg:     ProbeConfig{ProblemTemplate: "{{ .Nope }}"},
+			wantErr: "executing problem template",
+		},
+		{
+			// a merge request field rather than a check one
+			name:    "missing key",
+			cfg:     ProbeConfig{OKSummaryTemplate: "{{ .Project }} by {{ .Author }}"},
+			wantErr: "executing ok summary template",
+		},
+		{
+			name:    "empty summary",
+			cfg:     ProbeConfig{OKSummaryTemplate: "{{ if false }}never{{ end }}\n"},
+			wantErr: "ok summary template rendered an empty message",
+		},
+		{
+			name:    "empty summary without opened merge requests",
+			cfg:     ProbeConfig{OKSummaryTemplate: "{{ range .MergeRequests }}{{ .IID }} {{ end }}"},
+			wantErr: "ok summary template rendered an empty message",
+		},
+		{
+			name:    "summary failing without opened merge requests",
+			cfg:     ProbeConfig{OKSummaryTemplate: "{{ (index .MergeRequests 0).Title }}"},
+			wantErr: "executing ok summary template",
+		},
+		{
+			name:    "empty problem",
+			cfg:     ProbeConfig{ProblemTemplate: "   "},
+			wantErr: "problem template rendered an empty message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMessageTemplates(tt.cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("parseMessageTemplates() returned %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parseMessageTemplates() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteMessageTemplate(t *testing.T) {
+	templates, err := parseMessageTemplates(ProbeConfig{
+		OKSummaryTemplate:  "{{ .Project }}:\n{{ len .MergeRequests }} fine\n",
+		LongOutputTemplate: "first\nsecond\n\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := CheckTemplateData{Project: "group/project"}
+
+	// summaries are joined on a single line
+	if got, err := executeMessageTemplate(templates.okSummary, data, true); err != nil || got != "group/project: 0 fine" {
+		t.Errorf("ok summary = %q, %v", got, err)
+	}
+	// the long output keeps its lines
+	if got, err := executeMessageTemplate(templates.longOutput, data, false); err != nil || got != "first\nsecond" {
+		t.Errorf("long output = %q, %v", got, err)
+	}
+	// the built-in messages are kept
+	if got, err := executeMessageTemplate(templates.problem, data, true); err != nil || got != "" {
+		t.Errorf("problem = %q, %v", got, err)
+	}
+}
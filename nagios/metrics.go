@@ -0,0 +1,544 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+const (
+	OpenedMergeRequestsMetric          = "opened_merge_requests"
+	OldestMergeRequestMetric           = "oldest_merge_request"
+	MeanMergeRequestAgeMetric          = "mean_merge_request_age"
+	MedianMergeRequestAgeMetric        = "median_merge_request_age"
+	P90MergeRequestAgeMetric           = "p90_merge_request_age"
+	OlderMergeRequestsMetric           = "older_merge_requests"
+	DraftMergeRequestsMetric           = "draft_merge_requests"
+	UnassignedMergeRequestsMetric      = "unassigned_merge_requests"
+	WithoutReviewerMergeRequestsMetric = "without_reviewer_merge_requests"
+	ConflictingMergeRequestsMetric     = "conflicting_merge_requests"
+	FailedPipelineMergeRequestsMetric  = "failed_pipeline_merge_requests"
+	MergedMergeRequestsMetric          = "merged_merge_requests"
+	MergedPerDayMetric                 = "merged_merge_requests_per_day"
+	MeanTimeToMergeMetric              = "mean_time_to_merge"
+	MedianTimeToMergeMetric            = "median_time_to_merge"
+	P90TimeToMergeMetric               = "p90_time_to_merge"
+	MedianTimeToFirstReviewMetric      = "median_time_to_first_review"
+	P90TimeToFirstReviewMetric         = "p90_time_to_first_review"
+	ClosedMergeRequestsMetric          = "closed_merge_requests"
+	BotClosedMergeRequestsMetric       = "bot_closed_merge_requests"
+	ClosedMergedRatioMetric            = "closed_merged_ratio"
+	BranchesWithoutMergeRequestMetric  = "branches_without_merge_request"
+	StaleBranchesMetric                = "stale_branches"
+	OldestBranchMetric                 = "oldest_branch"
+	OpenedIssuesMetric                 = "opened_issues"
+	OldestIssueMetric                  = "oldest_issue"
+)
+
+// metricInput is what metrics are computed from
+type metricInput struct {
+	opened    []MergeRequest
+	merged    []MergeRequest
+	closed    []MergeRequest // closed without being merged
+	botClosed []MergeRequest // closed by a bot or a stale rule
+	branches  []Branch       // branches without merge request
+	issues    []Issue
+	now       time.Time
+	cfg       ProbeConfig
+}
+
+func (in metricInput) ages() []time.Duration {
+	ages := make([]time.Duration, len(in.opened))
+	for i, m := range in.opened {
+		ages[i] = in.now.Sub(m.UpdatedAt)
+	}
+	return ages
+}
+
+func (in metricInput) count(cond func(MergeRequest) bool) (float64, bool) {
+	return countMergeRequests(in.opened, cond), true
+}
+
+func countMergeRequests(mr []MergeRequest, cond func(MergeRequest) bool) float64 {
+	var n int
+	for _, m := range mr {
+		if cond(m) {
+			n++
+		}
+	}
+	return float64(n)
+}
+
+func (in metricInput) timesToMerge() durationStats {
+	var d []time.Duration
+	for _, m := range in.merged {
+		d = append(d, m.TimeToMerge())
+	}
+	return newDurationStats(d)
+}
+
+func (in metricInput) timesToFirstReview() durationStats {
+	var d []time.Duration
+	for _, m := range in.merged {
+		if ttfr, ok := m.TimeToFirstReview(); ok {
+			d = append(d, ttfr)
+		}
+	}
+	return newDurationStats(d)
+}
+
+var (
+	// modes reporting metrics about the opened merge requests
+	openedMetricModes = []string{MergeRequestsMode, CountMode, ReviewerLoadMode}
+	// modes reporting metrics about the recently merged merge requests
+	mergedMetricModes = []string{ThroughputMode}
+	// modes reporting metrics about the recently closed merge requests
+	closedMetricModes = []string{AbandonedMode}
+	// modes reporting metrics about the branches
+	branchMetricModes = []string{BranchesMode}
+	// modes reporting metrics about the opened issues
+	issueMetricModes = []string{IssuesMode}
+)
+
+// MetricDefinition describes a metric computed over the evaluated merge requests
+type MetricDefinition struct {
+	Name        string
+	Unit        string
+	Description string
+	Modes       []string // modes able to compute the metric
+
+	// value returns false when the metric cannot be computed
+	// (e.g. the median age of an empty set of merge requests)
+	value func(in metricInput) (float64, bool)
+}
+
+// MetricDefinitions is the registry of the metrics that can be
+// thresholded with --warning-metric / --critical-metric and
+// selected with --check-metric
+var MetricDefinitions = []MetricDefinition{
+	{
+		Name:        OpenedMergeRequestsMetric,
+		Description: "number of opened merge requests",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.opened)), true
+		},
+	},
+	{
+		Name:        ConflictingMergeRequestsMetric,
+		Description: "number of merge requests with conflicts",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool { return m.HasConflicts })
+		},
+	},
+	{
+		Name:        FailedPipelineMergeRequestsMetric,
+		Description: "number of merge requests with a failed head pipeline",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool { return m.HasFailedPipeline() })
+		},
+	},
+	{
+		Name:        DraftMergeRequestsMetric,
+		Description: "number of draft merge requests",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool { return m.Draft })
+		},
+	},
+	{
+		Name:        UnassignedMergeRequestsMetric,
+		Description: "number of merge requests without assignee",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool { return len(m.Assignees) == 0 })
+		},
+	},
+	{
+		Name:        WithoutReviewerMergeRequestsMetric,
+		Description: "number of merge requests without reviewer",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool { return len(m.Reviewers) == 0 })
+		},
+	},
+	{
+		Name:        OlderMergeRequestsMetric,
+		Description: "number of merge requests without activity for --older-than",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			if in.cfg.OlderThan <= 0 {
+				return 0, false
+			}
+			return in.count(func(m MergeRequest) bool { return in.now.Sub(m.UpdatedAt) >= in.cfg.OlderThan })
+		},
+	},
+	{
+		Name:        MeanMergeRequestAgeMetric,
+		Unit:        "s",
+		Description: "mean time elapsed since the merge requests last activity",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := newDurationStats(in.ages())
+			return stats.Mean.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        MedianMergeRequestAgeMetric,
+		Unit:        "s",
+		Description: "median time elapsed since the merge requests last activity",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := newDurationStats(in.ages())
+			return stats.Median.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        P90MergeRequestAgeMetric,
+		Unit:        "s",
+		Description: "90th percentile of the time elapsed since the merge requests last activity",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := newDurationStats(in.ages())
+			return stats.P90.Seconds(), stats.Count > 0
+		},
+	},
+	ageBucketMetric("merge_requests_age_lt_1h", "merge requests updated less than 1 hour ago", 0, time.Hour),
+	ageBucketMetric("merge_requests_age_lt_1d", "merge requests updated between 1 hour and 1 day ago", time.Hour, 24*time.Hour),
+	ageBucketMetric("merge_requests_age_lt_7d", "merge requests updated between 1 and 7 days ago", 24*time.Hour, 7*24*time.Hour),
+	ageBucketMetric("merge_requests_age_lt_30d", "merge requests updated between 7 and 30 days ago", 7*24*time.Hour, 30*24*time.Hour),
+	ageBucketMetric("merge_requests_age_ge_30d", "merge requests updated 30 days ago or more", 30*24*time.Hour, 0),
+	{
+		Name:        OldestMergeRequestMetric,
+		Unit:        "s",
+		Description: "time elapsed since the last activity of the oldest merge request",
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := newDurationStats(in.ages())
+			return stats.Max.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        MergedMergeRequestsMetric,
+		Description: "number of merge requests merged during --lookback",
+		Modes:       append(mergedMetricModes, closedMetricModes...),
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.merged)), true
+		},
+	},
+	{
+		Name:        MergedPerDayMetric,
+		Description: "average number of merge requests merged per day during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			days := in.cfg.Lookback.Hours() / 24
+			return float64(len(in.merged)) / days, days > 0
+		},
+	},
+	{
+		Name:        MeanTimeToMergeMetric,
+		Unit:        "s",
+		Description: "mean time between the creation and the merge of the merge requests merged during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := in.timesToMerge()
+			return stats.Mean.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        MedianTimeToMergeMetric,
+		Unit:        "s",
+		Description: "median time between the creation and the merge of the merge requests merged during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := in.timesToMerge()
+			return stats.Median.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        P90TimeToMergeMetric,
+		Unit:        "s",
+		Description: "90th percentile of the time between the creation and the merge of the merge requests merged during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := in.timesToMerge()
+			return stats.P90.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        MedianTimeToFirstReviewMetric,
+		Unit:        "s",
+		Description: "median time between the creation and the first review of the merge requests merged during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := in.timesToFirstReview()
+			return stats.Median.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        P90TimeToFirstReviewMetric,
+		Unit:        "s",
+		Description: "90th percentile of the time between the creation and the first review of the merge requests merged during --lookback",
+		Modes:       mergedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			stats := in.timesToFirstReview()
+			return stats.P90.Seconds(), stats.Count > 0
+		},
+	},
+	{
+		Name:        ClosedMergeRequestsMetric,
+		Description: "number of merge requests closed without being merged during --lookback",
+		Modes:       closedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.closed)), true
+		},
+	},
+	{
+		Name:        BotClosedMergeRequestsMetric,
+		Description: "number of merge requests closed by a bot or a stale rule during --lookback",
+		Modes:       closedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.botClosed)), true
+		},
+	},
+	{
+		Name:        ClosedMergedRatioMetric,
+		Description: "number of merge requests closed without being merged per merged one during --lookback",
+		Modes:       closedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.closed)) / float64(len(in.merged)), len(in.merged) > 0
+		},
+	},
+	{
+		Name:        BranchesWithoutMergeRequestMetric,
+		Description: "number of branches without opened merge request",
+		Modes:       branchMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.branches)), true
+		},
+	},
+	{
+		Name:        StaleBranchesMetric,
+		Description: "number of branches without opened merge request and without commit for --warning-last-update",
+		Modes:       branchMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			var n int
+			for _, b := range in.branches {
+				if in.now.Sub(b.LastCommitAt) >= in.cfg.WarningLastUpdateDelay {
+					n++
+				}
+			}
+			return float64(n), true
+		},
+	},
+	{
+		Name:        OldestBranchMetric,
+		Unit:        "s",
+		Description: "time elapsed since the last commit of the oldest branch without opened merge request",
+		Modes:       branchMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			var oldest time.Duration
+			for _, b := range in.branches {
+				if age := in.now.Sub(b.LastCommitAt); age > oldest {
+					oldest = age
+				}
+			}
+			return oldest.Seconds(), len(in.branches) > 0
+		},
+	},
+	{
+		Name:        OpenedIssuesMetric,
+		Description: "number of opened issues",
+		Modes:       issueMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return float64(len(in.issues)), true
+		},
+	},
+	{
+		Name:        OldestIssueMetric,
+		Unit:        "s",
+		Description: "time elapsed since the last activity of the oldest issue",
+		Modes:       issueMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			var oldest time.Duration
+			for _, i := range in.issues {
+				if age := in.now.Sub(i.UpdatedAt); age > oldest {
+					oldest = age
+				}
+			}
+			return oldest.Seconds(), len(in.issues) > 0
+		},
+	},
+}
+
+// ageBucketMetric counts the merge requests whose last activity
+// is in [from, to[. A zero to means no upper bound.
+func ageBucketMetric(name, description string, from, to time.Duration) MetricDefinition {
+	return MetricDefinition{
+		Name:        name,
+		Description: description,
+		Modes:       openedMetricModes,
+		value: func(in metricInput) (float64, bool) {
+			return in.count(func(m MergeRequest) bool {
+				age := in.now.Sub(m.UpdatedAt)
+				return age >= from && (to == 0 || age < to)
+			})
+		},
+	}
+}
+
+// ageDistributionMetrics are reported by default along with the opened merge requests count
+var ageDistributionMetrics = []string{
+	MeanMergeRequestAgeMetric,
+	MedianMergeRequestAgeMetric,
+	P90MergeRequestAgeMetric,
+	"merge_requests_age_lt_1h",
+	"merge_requests_age_lt_1d",
+	"merge_requests_age_lt_7d",
+	"merge_requests_age_lt_30d",
+	"merge_requests_age_ge_30d",
+}
+
+// initMetrics parses the metric thresholds, including the ones set
+// with the count mode shortcuts, and the metrics selected with --check-metric
+func (c *nagiosProbe) initMetrics() error {
+	warning := make(map[string]string)
+	critical := make(map[string]string)
+	for name, r := range c.cfg.WarningMetric {
+		warning[name] = r
+	}
+	for name, r := range c.cfg.CriticalMetric {
+		critical[name] = r
+	}
+
+	for name, r := range map[string]string{OpenedMergeRequestsMetric: c.cfg.WarningOpened, OlderMergeRequestsMetric: c.cfg.WarningOlder} {
+		if r != "" {
+			warning[name] = r
+		}
+	}
+	for name, r := range map[string]string{OpenedMergeRequestsMetric: c.cfg.CriticalOpened, OlderMergeRequestsMetric: c.cfg.CriticalOlder} {
+		if r != "" {
+			critical[name] = r
+		}
+	}
+
+	var err error
+	if c.metricThresholds, err = parseMetricThresholds(warning, critical, MetricNames()); err != nil {
+		return errors.Wrap(err, "parsing metric thresholds")
+	}
+
+	for name := range c.metricThresholds {
+		if d, _ := lookupMetric(name); !d.availableIn(c.cfg.Mode) {
+			return fmt.Errorf("metric %q is not available in %s mode", name, c.cfg.Mode)
+		}
+	}
+
+	c.checkedMetrics = make(map[string]bool)
+	for _, name := range c.cfg.CheckMetrics {
+		if _, ok := c.metricThresholds[name]; !ok {
+			return fmt.Errorf("metric %q selected with --check-metric has neither a warning nor a critical range", name)
+		}
+		c.checkedMetrics[name] = true
+	}
+
+	return nil
+}
+
+// usesMetric returns true if the metric has thresholds
+func (c nagiosProbe) usesMetric(name string) bool {
+	_, ok := c.metricThresholds[name]
+	return ok
+}
+
+func lookupMetric(name string) (MetricDefinition, bool) {
+	for _, d := range MetricDefinitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return MetricDefinition{}, false
+}
+
+func (d MetricDefinition) availableIn(mode string) bool {
+	for _, m := range d.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricNames returns the names of every registered metric
+func MetricNames() []string {
+	names := make([]string, len(MetricDefinitions))
+	for i, d := range MetricDefinitions {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// MetricsHelp returns a human readable list of the registered metrics
+func MetricsHelp() string {
+	var sb strings.Builder
+	sb.WriteString("Available metrics:\n")
+	for _, d := range MetricDefinitions {
+		fmt.Fprintf(&sb, "  %-32s [%s] %s\n", d.Name, strings.Join(d.Modes, ","), d.Description)
+	}
+	return sb.String()
+}
+
+// formatMetricValue renders value for humans, the durations worded in locale
+func formatMetricValue(value float64, unit, locale string) string {
+	if unit == "s" {
+		return HumanizeDuration(time.Duration(value*float64(time.Second)), locale)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// checkMetrics computes the registered metrics over mr and adds them to the perfdata.
+// Only the metrics listed in defaults, the ones having thresholds and the ones
+// selected with --check-metric are reported.
+// The thresholds of a metric only alter the check status if no metric
+// was selected with --check-metric, or if the metric is selected.
+func (c nagiosProbe) checkMetrics(in metricInput, defaults ...string) {
+	in.now = time.Now()
+	in.cfg = c.cfg
+
+	isDefault := make(map[string]bool)
+	for _, name := range defaults {
+		isDefault[name] = true
+	}
+
+	for _, d := range MetricDefinitions {
+		if !d.availableIn(c.cfg.Mode) {
+			continue
+		}
+
+		t, thresholded := c.metricThresholds[d.Name]
+		selected := c.checkedMetrics[d.Name]
+		if !isDefault[d.Name] && !thresholded && !selected {
+			continue
+		}
+
+		value, ok := d.value(in)
+		if !ok {
+			if thresholded || selected {
+				c.nagCheck.AddPerfDatum(d.Name, d.Unit, nagiosplugin.NewUndeterminedPerfDatumValue(), t.warning, t.critical, nil, nil)
+			}
+			continue
+		}
+		t.addPerfDatum(c.nagCheck, d.Name, d.Unit, value)
+
+		if !thresholded || (len(c.checkedMetrics) > 0 && !selected) {
+			continue
+		}
+		if status := t.status(value); status != nagiosplugin.OK {
+			c.nagCheck.AddResultf(status, localize(c.cfg.Locale, "%s is %s"), d.Name, formatMetricValue(value, d.Unit, c.cfg.Locale))
+		}
+	}
+}
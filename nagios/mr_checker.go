@@ -1,5 +1,64 @@
 package nagios
 
+import (
+	"fmt"
+	"time"
+)
+
 type GitMergeRequestChecker interface {
 	CheckMergeRequests(project string, targetBranch string) ([]MergeRequest, error)
+	// ListMergeRequests returns the project merge requests matching opts
+	ListMergeRequests(project string, opts ListMergeRequestsOptions) ([]MergeRequest, error)
+	// ListGroupProjects returns the full path of the projects of a group
+	// (Gitlab group or Github organization)
+	ListGroupProjects(group string) ([]string, error)
+	// ListBranches returns the branches of the project
+	ListBranches(project string) ([]Branch, error)
+}
+
+// ListMergeRequestsOptions selects the merge requests to list
+type ListMergeRequestsOptions struct {
+	State        string    // one of the MergeRequestState* constants
+	TargetBranch string    // empty for any target branch
+	UpdatedAfter time.Time // zero for no lower bound
+}
+
+// mrCheckerOptions tells the provider implementations which
+// (potentially expensive) extra details must be fetched for every merge request
+type mrCheckerOptions struct {
+	WithMergeability   bool
+	WithPipelineStatus bool
+	WithFirstReview    bool
+	WithCloser         bool
+}
+
+func isSupportedGitProvider(provider string) bool {
+	for _, p := range SupportedGitProviders {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// mrCheckerOptions returns the extra details the probe needs
+func (c nagiosProbe) mrCheckerOptions() mrCheckerOptions {
+	return mrCheckerOptions{
+		WithMergeability:   c.cfg.CheckConflicts || c.usesMetric(ConflictingMergeRequestsMetric),
+		WithPipelineStatus: c.cfg.CheckFailedPipelines || c.usesMetric(FailedPipelineMergeRequestsMetric),
+		WithFirstReview:    c.cfg.Mode == ThroughputMode,
+		WithCloser:         c.cfg.ListBotClosed || c.usesMetric(BotClosedMergeRequestsMetric),
+	}
+}
+
+// newGitMergeRequestChecker returns the GitMergeRequestChecker implementation
+// matching the configured git provider
+func newGitMergeRequestChecker(cfg ProbeConfig, opts mrCheckerOptions) (GitMergeRequestChecker, error) {
+	switch cfg.GitProvider {
+	case GitlabGitProvider:
+		return newGitlabProjectMRChecker(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient(), opts)
+	case GithubGitProvider:
+		return newGithubProjectMRChecker(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient(), opts)
+	}
+	return nil, fmt.Errorf("git provider %s is not supported yet", cfg.GitProvider)
 }
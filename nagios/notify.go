@@ -0,0 +1,343 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/report"
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// NotifyFormatSlack renders the digest links with the Slack mrkdwn syntax
+	NotifyFormatSlack = "slack"
+	// NotifyFormatMarkdown renders the digest links with the Markdown syntax (Mattermost, Teams)
+	NotifyFormatMarkdown = "markdown"
+)
+
+// NotifyFormats lists the formats the digests can be posted in
+var NotifyFormats = []string{NotifyFormatSlack, NotifyFormatMarkdown}
+
+// NotifyChannel routes the digests of the projects it matches.
+// The first channel matching a project is used.
+type NotifyChannel struct {
+	Project    string `mapstructure:"project"`     // shell pattern, e.g. riton/*
+	Channel    string `mapstructure:"channel"`     // channel override, ignored by most Slack webhooks
+	WebhookURL string `mapstructure:"webhook-url"` // defaults to the global webhook URL
+}
+
+// webhookNotifier posts a digest of the offending merge requests to
+// Slack, Mattermost or Teams compatible incoming webhooks
+type webhookNotifier struct {
+	url       string
+	channels  []NotifyChannel
+	format    string
+	stateFile string
+	interval  time.Duration
+	locale    string
+}
+
+// newWebhookNotifier returns the notifier configured in cfg, or nil if notifications are disabled
+func newWebhookNotifier(cfg ProbeConfig) (*webhookNotifier, error) {
+	if cfg.NotifyWebhookURL == "" && len(cfg.NotifyChannels) == 0 {
+		return nil, nil
+	}
+
+	n := &webhookNotifier{
+		url:       cfg.NotifyWebhookURL,
+		channels:  cfg.NotifyChannels,
+		format:    cfg.NotifyFormat,
+		stateFile: cfg.NotifyStateFile,
+		interval:  cfg.NotifyInterval,
+		locale:    cfg.Locale,
+	}
+
+	if n.format != NotifyFormatSlack && n.format != NotifyFormatMarkdown {
+		return nil, fmt.Errorf("unsupported notify format %q (expected one of %s)", n.format, strings.Join(NotifyFormats, ","))
+	}
+	if err := checkLocale(n.locale); err != nil {
+		return nil, err
+	}
+	if n.stateFile == "" {
+		return nil, errors.New("a notify state file is required")
+	}
+	if n.interval < 0 {
+		return nil, errors.New("notify interval must not be negative")
+	}
+	if err := validateWebhookURL(n.url); n.url != "" && err != nil {
+		return nil, err
+	}
+	for i, c := range n.channels {
+		if c.Project == "" {
+			return nil, fmt.Errorf("notify channel #%d: a project pattern is required", i+1)
+		}
+		if _, err := path.Match(c.Project, ""); err != nil {
+			return nil, errors.Wrapf(err, "notify channel #%d: parsing project pattern %q", i+1, c.Project)
+		}
+		if c.WebhookURL == "" && n.url == "" {
+			return nil, fmt.Errorf("notify channel #%d: a webhook URL is required", i+1)
+		}
+		if err := validateWebhookURL(c.WebhookURL); c.WebhookURL != "" && err != nil {
+			return nil, errors.Wrapf(err, "notify channel #%d", i+1)
+		}
+	}
+
+	return n, nil
+}
+
+func validateWebhookURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return errors.Wrap(err, "parsing webhook url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url %q must be an http or https url", webhookURL)
+	}
+	return nil
+}
+
+// webhookTarget is where the digest of a project is posted
+type webhookTarget struct {
+	url     string
+	channel string
+}
+
+// target returns where the digest of project is posted, false if nowhere
+func (n *webhookNotifier) target(project string) (webhookTarget, bool) {
+	for _, c := range n.channels {
+		if ok, _ := path.Match(c.Project, project); !ok {
+			continue
+		}
+		t := webhookTarget{url: c.WebhookURL, channel: c.Channel}
+		if t.url == "" {
+			t.url = n.url
+		}
+		return t, true
+	}
+	return webhookTarget{url: n.url}, n.url != ""
+}
+
+// notifyState remembers the digests posted, so that the same problem
+// is not posted again on every check run
+type notifyState struct {
+	Digests map[string]notifyStateEntry `json:"digests"`
+}
+
+type notifyStateEntry struct {
+	// Check is the project, group or list of projects of the check
+	// the digest was posted for, Project the one of the digest
+	Check   string    `json:"check"`
+	Project string    `json:"project"`
+	SentAt  time.Time `json:"sent_at"`
+	Status  string    `json:"status"`
+}
+
+func notifyStateKey(project string, t webhookTarget) string {
+	if t.channel == "" {
+		return project
+	}
+	return project + " " + t.channel
+}
+
+func loadNotifyState(file string) (notifyState, error) {
+	state := notifyState{Digests: make(map[string]notifyStateEntry)}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, errors.Wrapf(err, "reading %s", file)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, errors.Wrapf(err, "decoding %s", file)
+	}
+	if state.Digests == nil {
+		state.Digests = make(map[string]notifyStateEntry)
+	}
+	return state, nil
+}
+
+func (s notifyState) save(file string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding notify state")
+	}
+	return writeFileAtomic(file, append(data, '\n'))
+}
+
+// Notify posts the digests of the merge requests that are not OK, grouped by project.
+// check is the project, group or list of projects the check ran on.
+// A digest is posted again after the interval or if the status of the project worsened.
+// Once a project is back to OK, its next problem is posted right away.
+// The state file is locked meanwhile, as checks running at the same time share it.
+func (n *webhookNotifier) Notify(ctx context.Context, check string, status nagiosplugin.Status, mr []report.MergeRequest, now time.Time) error {
+	if status != nagiosplugin.OK && status != nagiosplugin.WARNING && status != nagiosplugin.CRITICAL {
+		return nil
+	}
+
+	lock, err := lockFile(ctx, n.stateFile+".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	state, err := loadNotifyState(n.stateFile)
+	if err != nil {
+		return err
+	}
+
+	var projects []string
+	offending := make(map[string][]report.MergeRequest)
+	for _, m := range mr {
+		if m.Status != nagiosplugin.WARNING.String() && m.Status != nagiosplugin.CRITICAL.String() {
+			continue
+		}
+		if _, ok := offending[m.Project]; !ok {
+			projects = append(projects, m.Project)
+		}
+		offending[m.Project] = append(offending[m.Project], m)
+	}
+
+	// forget the digests of the projects of this check that are back to OK
+	changed := false
+	for key, entry := range state.Digests {
+		if _, ok := offending[entry.Project]; entry.Check == check && !ok {
+			delete(state.Digests, key)
+			changed = true
+		}
+	}
+	if len(projects) == 0 {
+		if !changed {
+			return nil
+		}
+		return state.save(n.stateFile)
+	}
+
+	var errs []string
+	for _, p := range projects {
+		t, ok := n.target(p)
+		if !ok {
+			log.WithField("project", p).Debug("no webhook to notify")
+			continue
+		}
+
+		projectStatus := worstMergeRequestStatus(offending[p])
+		key := notifyStateKey(p, t)
+		if last, ok := state.Digests[key]; ok && now.Sub(last.SentAt) < n.interval {
+			lastStatus, _ := parseStatus(last.Status)
+			if projectStatus <= lastStatus {
+				log.WithFields(log.Fields{
+					"project": p,
+					"sent-at": last.SentAt,
+				}).Debug("digest already posted, not notifying")
+				continue
+			}
+		}
+
+		if err := n.post(ctx, t, n.digest(p, projectStatus, offending[p], now)); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		log.WithFields(log.Fields{
+			"project": p,
+			"channel": t.channel,
+		}).Debug("digest posted successfully")
+		state.Digests[key] = notifyStateEntry{Check: check, Project: p, SentAt: now, Status: projectStatus.String()}
+	}
+
+	if err := state.save(n.stateFile); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func worstMergeRequestStatus(mr []report.MergeRequest) nagiosplugin.Status {
+	worst := nagiosplugin.OK
+	for _, m := range mr {
+		if s, err := parseStatus(m.Status); err == nil && s > worst {
+			worst = s
+		}
+	}
+	return worst
+}
+
+var (
+	slackEscaper    = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	markdownEscaper = strings.NewReplacer("[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`)
+)
+
+// digest renders the message listing the offending merge requests of project
+func (n *webhookNotifier) digest(project string, status nagiosplugin.Status, mr []report.MergeRequest, now time.Time) string {
+	var lines []string
+	if n.format == NotifyFormatSlack {
+		lines = append(lines, fmt.Sprintf(localize(n.locale, "*%s*: %d merge requests of %s need attention"), status, len(mr), slackEscaper.Replace(project)))
+	} else {
+		lines = append(lines, fmt.Sprintf(localize(n.locale, "**%s**: %d merge requests of %s need attention"), status, len(mr), markdownEscaper.Replace(project)))
+	}
+
+	for _, m := range mr {
+		var line string
+		if n.format == NotifyFormatSlack {
+			line = fmt.Sprintf(localize(n.locale, "• <%s|%s> by %s"), m.WebURL, slackEscaper.Replace(m.Title), slackEscaper.Replace(m.Author))
+		} else {
+			line = fmt.Sprintf(localize(n.locale, "- [%s](%s) by %s"), markdownEscaper.Replace(m.Title), m.WebURL, markdownEscaper.Replace(m.Author))
+		}
+		line += fmt.Sprintf(localize(n.locale, ", last activity %s ago, opened %s ago"), HumanizeDuration(now.Sub(m.UpdatedAt), n.locale), HumanizeDuration(now.Sub(m.CreatedAt), n.locale))
+		if len(m.Reasons) > 0 {
+			line += ": " + strings.Join(m.Reasons, ", ")
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// webhookPayload is understood by Slack, Mattermost and Teams incoming webhooks
+type webhookPayload struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+func (n *webhookNotifier) post(ctx context.Context, t webhookTarget, text string) error {
+	body, err := json.Marshal(webhookPayload{Text: text, Channel: t.channel})
+	if err != nil {
+		return errors.Wrap(err, "encoding digest")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// the webhook URL embeds its secret, it is never part of the errors
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrapf(err, "posting to %s", req.URL.Host)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("posting to %s: %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
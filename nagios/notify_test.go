@@ -0,0 +1,188 @@
+package nagios
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/report"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// webhookStandIn records the digests posted to it
+type webhookStandIn struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	digests []string
+}
+
+func newWebhookStandIn(t *testing.T) *webhookStandIn {
+	w := &webhookStandIn{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decoding digest: %s", err)
+		}
+		w.mu.Lock()
+		w.digests = append(w.digests, payload.Text)
+		w.mu.Unlock()
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+// posted returns the digests posted since the last call
+func (w *webhookStandIn) posted() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	digests := w.digests
+	w.digests = nil
+	return digests
+}
+
+func TestWebhookNotifierNotify(t *testing.T) {
+	webhook := newWebhookStandIn(t)
+	n := &webhookNotifier{
+		url:       webhook.URL,
+		format:    NotifyFormatSlack,
+		stateFile: filepath.Join(t.TempDir(), "notify.json"),
+		interval:  6 * time.Hour,
+		locale:    LocaleEnglish,
+	}
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	mergeRequest := func(project, status string) report.MergeRequest {
+		return report.MergeRequest{
+			IID:       1,
+			Project:   project,
+			Title:     "Fix the build",
+			Author:    "alice",
+			WebURL:    "https://gitlab.example.com/" + project + "/-/merge_requests/1",
+			CreatedAt: now.Add(-72 * time.Hour),
+			UpdatedAt: now.Add(-30 * time.Hour),
+			Status:    status,
+			Reasons:   []string{"has conflicts"},
+		}
+	}
+	notify := func(check string, status nagiosplugin.Status, at time.Time, mr ...report.MergeRequest) {
+		t.Helper()
+		if err := n.Notify(context.Background(), check, status, mr, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := "group/a,group/b"
+	notify(check, nagiosplugin.WARNING, now, mergeRequest("group/a", "WARNING"), mergeRequest("group/b", "OK"))
+	digests := webhook.posted()
+	if len(digests) != 1 {
+		t.Fatalf("posted %d digests, want 1", len(digests))
+	}
+	want := "*WARNING*: 1 merge requests of group/a need attention\n" +
+		"• <https://gitlab.example.com/group/a/-/merge_requests/1|Fix the build> by alice, last activity 1d 6h ago, opened 3d ago: has conflicts"
+	if digests[0] != want {
+		t.Errorf("digest = %q\nwant     %q", digests[0], want)
+	}
+
+	// suppressed inside the interval
+	notify(check, nagiosplugin.WARNING, now.Add(time.Hour), mergeRequest("group/a", "WARNING"))
+	if digests := webhook.posted(); len(digests) != 0 {
+		t.Errorf("posted %q inside the interval", digests)
+	}
+
+	// posted again when the status worsens
+	notify(check, nagiosplugin.CRITICAL, now.Add(2*time.Hour), mergeRequest("group/a", "CRITICAL"))
+	if digests := webhook.posted(); len(digests) != 1 || !strings.HasPrefix(digests[0], "*CRITICAL*") {
+		t.Errorf("posted %q when the status worsened, want a CRITICAL digest", digests)
+	}
+
+	// then not when it gets better
+	notify(check, nagiosplugin.WARNING, now.Add(3*time.Hour), mergeRequest("group/a", "WARNING"))
+	if digests := webhook.posted(); len(digests) != 0 {
+		t.Errorf("posted %q when the status got better", digests)
+	}
+
+	// posted again after the interval
+	notify(check, nagiosplugin.WARNING, now.Add(9*time.Hour), mergeRequest("group/a", "WARNING"))
+	if digests := webhook.posted(); len(digests) != 1 {
+		t.Errorf("posted %d digests after the interval, want 1", len(digests))
+	}
+
+	// another check sharing the state file is not affected by this one
+	notify("group/c", nagiosplugin.CRITICAL, now.Add(9*time.Hour), mergeRequest("group/c", "CRITICAL"))
+	webhook.posted()
+
+	// forgotten once back to OK, the next problem is posted right away
+	notify(check, nagiosplugin.OK, now.Add(10*time.Hour), mergeRequest("group/a", "OK"))
+	state, err := loadNotifyState(n.stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Digests["group/a"]; ok {
+		t.Errorf("state %+v still has group/a once back to OK", state.Digests)
+	}
+	if _, ok := state.Digests["group/c"]; !ok {
+		t.Errorf("state %+v lost the digest of another check", state.Digests)
+	}
+	notify(check, nagiosplugin.WARNING, now.Add(11*time.Hour), mergeRequest("group/a", "WARNING"))
+	if digests := webhook.posted(); len(digests) != 1 {
+		t.Errorf("posted %d digests after the recovery, want 1", len(digests))
+	}
+}
+
+func TestWebhookNotifierConcurrentRuns(t *testing.T) {
+	webhook := newWebhookStandIn(t)
+	stateFile := filepath.Join(t.TempDir(), "notify.json")
+	now := time.Now()
+
+	// every check keeps its digest despite the others saving the state meanwhile
+	var wg sync.WaitGroup
+	projects := []string{"group/a", "group/b", "group/c", "group/d", "group/e", "group/f"}
+	for _, p := range projects {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			n := &webhookNotifier{url: webhook.URL, format: NotifyFormatMarkdown, stateFile: stateFile, interval: time.Hour, locale: LocaleEnglish}
+			mr := []report.MergeRequest{{IID: 1, Project: p, Status: "CRITICAL", CreatedAt: now, UpdatedAt: now}}
+			if err := n.Notify(context.Background(), p, nagiosplugin.CRITICAL, mr, now); err != nil {
+				t.Error(err)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	state, err := loadNotifyState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Digests) != len(projects) {
+		t.Errorf("state has %d digests, want %d", len(state.Digests), len(projects))
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notify.json.lock")
+	lock, err := lockFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := lockFile(ctx, file); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("lockFile() of a locked file error = %v, want a deadline exceeded error", err)
+	}
+
+	lock.Close()
+	lock, err = lockFile(context.Background(), file)
+	if err != nil {
+		t.Fatalf("lockFile() once released returned %v", err)
+	}
+	lock.Close()
+}
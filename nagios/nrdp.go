@@ -0,0 +1,235 @@
+package nagios
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	NRDPFormatJSON = "json"
+	NRDPFormatXML  = "xml"
+
+	// nrdpInitialBackoff is the delay before the first retry, doubled on every retry
+	nrdpInitialBackoff = time.Second
+)
+
+// NRDPFormats lists the formats check results can be submitted to NRDP in
+var NRDPFormats = []string{NRDPFormatJSON, NRDPFormatXML}
+
+// nrdpSubmitter submits check results to a Nagios Remote Data Processor
+type nrdpSubmitter struct {
+	url     string
+	token   string
+	format  string
+	retries int
+	backoff time.Duration // before the first retry, doubled on every retry
+	// dryRun prints the payload to out instead of submitting it
+	dryRun bool
+	out    io.Writer
+}
+
+func newNRDPSubmitter(cfg ProbeConfig, out io.Writer) (nrdpSubmitter, error) {
+	s := nrdpSubmitter{
+		url:     cfg.NRDPURL,
+		token:   cfg.NRDPToken,
+		format:  cfg.NRDPFormat,
+		retries: cfg.NRDPRetries,
+		backoff: nrdpInitialBackoff,
+		dryRun:  cfg.NRDPDryRun,
+		out:     out,
+	}
+
+	u, err := url.Parse(cfg.NRDPURL)
+	if err != nil {
+		return s, errors.Wrap(err, "parsing NRDP url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return s, fmt.Errorf("NRDP url %q must be an http or https url", cfg.NRDPURL)
+	}
+	if s.format != NRDPFormatJSON && s.format != NRDPFormatXML {
+		return s, fmt.Errorf("unsupported NRDP format %q (expected one of %s)", s.format, strings.Join(NRDPFormats, ","))
+	}
+	if s.retries < 0 {
+		return s, errors.New("NRDP retries must not be negative")
+	}
+	if s.token == "" && !s.dryRun {
+		return s, errors.New("an NRDP token is required")
+	}
+
+	return s, nil
+}
+
+func (s nrdpSubmitter) Name() string {
+	return "nrdp"
+}
+
+type nrdpJSONCheckResults struct {
+	CheckResults []nrdpJSONCheckResult `json:"checkresults"`
+}
+
+type nrdpJSONCheckResult struct {
+	CheckResult struct {
+		Type      string `json:"type"`
+		CheckType string `json:"checktype"`
+	} `json:"checkresult"`
+	HostName    string `json:"hostname"`
+	ServiceName string `json:"servicename"`
+	State       string `json:"state"`
+	Output      string `json:"output"`
+}
+
+type nrdpXMLCheckResults struct {
+	XMLName      xml.Name             `xml:"checkresults"`
+	CheckResults []nrdpXMLCheckResult `xml:"checkresult"`
+}
+
+type nrdpXMLCheckResult struct {
+	Type        string `xml:"type,attr"`
+	CheckType   string `xml:"checktype,attr"`
+	HostName    string `xml:"hostname"`
+	ServiceName string `xml:"servicename"`
+	State       string `xml:"state"`
+	Output      string `xml:"output"`
+}
+
+// payload returns the check results document and the form field it is sent in
+func (s nrdpSubmitter) payload(r passiveResult) (string, string, error) {
+	output := strings.ReplaceAll(r.Output, "|", "/")
+	if len(r.Perfdata) > 0 {
+		output += "|" + strings.Join(r.Perfdata, " ")
+	}
+	state := strconv.Itoa(int(r.Status))
+
+	if s.format == NRDPFormatXML {
+		doc, err := xml.MarshalIndent(nrdpXMLCheckResults{
+			CheckResults: []nrdpXMLCheckResult{{
+				// checktype 1 is a passive check
+				Type:        "service",
+				CheckType:   "1",
+				HostName:    r.Host,
+				ServiceName: r.Service,
+				State:       state,
+				Output:      output,
+			}},
+		}, "", "  ")
+		if err != nil {
+			return "", "", err
+		}
+		return "XMLDATA", xml.Header + string(doc), nil
+	}
+
+	result := nrdpJSONCheckResult{
+		HostName:    r.Host,
+		ServiceName: r.Service,
+		State:       state,
+		Output:      output,
+	}
+	result.CheckResult.Type = "service"
+	result.CheckResult.CheckType = "1"
+	doc, err := json.MarshalIndent(nrdpJSONCheckResults{CheckResults: []nrdpJSONCheckResult{result}}, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	return "JSONDATA", string(doc), nil
+}
+
+// nrdpResponse is the answer of NRDP, in JSON or XML
+type nrdpResponse struct {
+	Result struct {
+		Status  json.Number `json:"status" xml:"status"`
+		Message string      `json:"message" xml:"message"`
+	} `json:"result"`
+}
+
+// errNRDPPermanent flags the failures retrying would not fix
+type errNRDPPermanent struct {
+	error
+}
+
+func (s nrdpSubmitter) Submit(ctx context.Context, r passiveResult) error {
+	field, doc, err := s.payload(r)
+	if err != nil {
+		return errors.Wrap(err, "encoding check result")
+	}
+
+	if s.dryRun {
+		fmt.Fprintln(s.out, doc)
+		return nil
+	}
+
+	form := url.Values{}
+	form.Set("token", s.token)
+	form.Set("cmd", "submitcheck")
+	form.Set(field, doc)
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, form)
+		if err == nil {
+			return nil
+		}
+		if _, permanent := err.(errNRDPPermanent); permanent || attempt >= s.retries {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"error":   err,
+			"attempt": attempt + 1,
+			"backoff": backoff,
+		}).Debug("NRDP submission failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "retrying after %s", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s nrdpSubmitter) post(ctx context.Context, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errNRDPPermanent{errors.Wrap(err, "creating request")}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "posting to %s", s.url)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("posting to %s: %s: %s", s.url, resp.Status, strings.TrimSpace(string(body)))
+		// server side and throttling failures are worth retrying
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return err
+		}
+		return errNRDPPermanent{err}
+	}
+
+	var nrdpResp nrdpResponse
+	if err := json.Unmarshal(body, &nrdpResp); err != nil {
+		if err := xml.Unmarshal(body, &nrdpResp.Result); err != nil {
+			return errNRDPPermanent{fmt.Errorf("decoding response %q", strings.TrimSpace(string(body)))}
+		}
+	}
+	if nrdpResp.Result.Status.String() != "0" {
+		return errNRDPPermanent{fmt.Errorf("NRDP refused the check result: %s", nrdpResp.Result.Message)}
+	}
+	return nil
+}
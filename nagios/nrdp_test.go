@@ -0,0 +1,204 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// nrdpStandIn answers the submissions with the given responses in turn,
+// the last one repeated
+type nrdpStandIn struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	forms []map[string]string
+}
+
+type nrdpStandInResponse struct {
+	code int
+	body string
+}
+
+func newNRDPStandIn(t *testing.T, responses ...nrdpStandInResponse) *nrdpStandIn {
+	s := &nrdpStandIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parsing form: %s", err)
+		}
+		s.mu.Lock()
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		s.forms = append(s.forms, form)
+		resp := responses[len(responses)-1]
+		if len(s.forms) <= len(responses) {
+			resp = responses[len(s.forms)-1]
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(resp.code)
+		w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *nrdpStandIn) submissions() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forms
+}
+
+var nrdpTestResult = passiveResult{
+	Host:     "gitlab",
+	Service:  "merge requests project",
+	Status:   nagiosplugin.WARNING,
+	Output:   "WARNING: Merge request 12 last activity was 7h ago",
+	Perfdata: []string{"'opened_merge_requests'=2;;;;"},
+}
+
+func newNRDPTestSubmitter(t *testing.T, url string, retries int) nrdpSubmitter {
+	s, err := newNRDPSubmitter(ProbeConfig{
+		NRDPURL:     url,
+		NRDPToken:   "secret",
+		NRDPFormat:  NRDPFormatJSON,
+		NRDPRetries: retries,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backoff = time.Millisecond
+	return s
+}
+
+func TestNRDPSubmitterSubmit(t *testing.T) {
+	const accepted = `{"result":{"status":"0","message":"OK","meta":{"output":"1 checks processed."}}}`
+	tests := []struct {
+		name        string
+		retries     int
+		responses   []nrdpStandInResponse
+		submissions int
+		wantErr     string
+	}{
+		{
+			name:        "accepted",
+			retries:     2,
+			responses:   []nrdpStandInResponse{{200, accepted}},
+			submissions: 1,
+		},
+		{
+			name:        "retry then success",
+			retries:     2,
+			responses:   []nrdpStandInResponse{{503, "unavailable"}, {429, "slow down"}, {200, accepted}},
+			submissions: 3,
+		},
+		{
+			name:        "retries exhausted",
+			retries:     1,
+			responses:   []nrdpStandInResponse{{502, "bad gateway"}},
+			submissions: 2,
+			wantErr:     "502 Bad Gateway: bad gateway",
+		},
+		{
+			name:        "no retry on 4xx",
+			retries:     2,
+			responses:   []nrdpStandInResponse{{403, "forbidden"}},
+			submissions: 1,
+			wantErr:     "403 Forbidden: forbidden",
+		},
+		{
+			name:        "refused",
+			retries:     2,
+			responses:   []nrdpStandInResponse{{200, `{"result":{"status":"-1","message":"BAD TOKEN"}}`}},
+			submissions: 1,
+			wantErr:     "NRDP refused the check result: BAD TOKEN",
+		},
+		{
+			name:        "refused in XML",
+			retries:     2,
+			responses:   []nrdpStandInResponse{{200, `<result><status>-1</status><message>NO DATA</message></result>`}},
+			submissions: 1,
+			wantErr:     "NRDP refused the check result: NO DATA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nrdp := newNRDPStandIn(t, tt.responses...)
+			err := newNRDPTestSubmitter(t, nrdp.URL, tt.retries).Submit(context.Background(), nrdpTestResult)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Submit() returned %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Submit() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := len(nrdp.submissions()); got != tt.submissions {
+				t.Errorf("got %d submissions, want %d", got, tt.submissions)
+			}
+		})
+	}
+
+	// the submitted form
+	nrdp := newNRDPStandIn(t, nrdpStandInResponse{200, accepted})
+	if err := newNRDPTestSubmitter(t, nrdp.URL, 0).Submit(context.Background(), nrdpTestResult); err != nil {
+		t.Fatal(err)
+	}
+	form := nrdp.submissions()[0]
+	if form["token"] != "secret" || form["cmd"] != "submitcheck" {
+		t.Errorf("form = %v", form)
+	}
+	var doc nrdpJSONCheckResults
+	if err := json.Unmarshal([]byte(form["JSONDATA"]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.CheckResults) != 1 {
+		t.Fatalf("JSONDATA = %s", form["JSONDATA"])
+	}
+	r := doc.CheckResults[0]
+	if r.HostName != "gitlab" || r.ServiceName != "merge requests project" || r.State != "1" || r.CheckResult.CheckType != "1" ||
+		r.Output != "WARNING: Merge request 12 last activity was 7h ago|'opened_merge_requests'=2;;;;" {
+		t.Errorf("check result = %+v", r)
+	}
+}
+
+func TestNRDPSubmitterDryRun(t *testing.T) {
+	nrdp := newNRDPStandIn(t, nrdpStandInResponse{500, "unexpected"})
+
+	var out bytes.Buffer
+	s, err := newNRDPSubmitter(ProbeConfig{
+		NRDPURL:    nrdp.URL,
+		NRDPFormat: NRDPFormatXML,
+		NRDPDryRun: true,
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit(context.Background(), nrdpTestResult); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<checkresult type="service" checktype="1">`,
+		"<hostname>gitlab</hostname>",
+		"<servicename>merge requests project</servicename>",
+		"<state>1</state>",
+		"<output>WARNING: Merge request 12 last activity was 7h ago|&#39;opened_merge_requests&#39;=2;;;;</output>",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("payload %q does not contain %q", out.String(), want)
+		}
+	}
+	if n := len(nrdp.submissions()); n != 0 {
+		t.Errorf("got %d submissions in dry-run, want none", n)
+	}
+}
@@ -0,0 +1,175 @@
+package nagios
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultNudgeTemplate is the comment posted on the merge requests without activity
+const DefaultNudgeTemplate = "@{{ .Author }} this merge request has had no activity for {{ humanize .LastActivity }}. Is it still relevant?"
+
+// nudgeMarker is hidden in the nudge comments to find the previous nudges
+const nudgeMarker = "<!-- nagios-plugin-git-hosted-project-merge-requests:nudge -->"
+
+// Nudge is what was done, or would be done with a dry run, on a merge request
+type Nudge struct {
+	MergeRequest MergeRequest
+	LastActivity time.Duration
+	Comment      string   // comment posted, without the marker
+	Labels       []string // labels added
+	// PreviousNudge is the time of the nudge within the cooldown the merge
+	// request was skipped for, zero if the merge request was nudged
+	PreviousNudge time.Time
+	Err           error
+}
+
+// Skipped tells whether the merge request was left alone because of a recent nudge
+func (n Nudge) Skipped() bool {
+	return !n.PreviousNudge.IsZero()
+}
+
+// NudgeMergeRequests comments the opened merge requests of the configured project,
+// projects or group without activity for the warning delay, and adds the nudge label.
+// Merge requests already nudged within the cooldown are skipped.
+// Failures on a merge request are reported in its Nudge, the others are still nudged.
+func NudgeMergeRequests(cfg ProbeConfig) ([]Nudge, error) {
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.initSelection(); err != nil {
+		return nil, err
+	}
+	if err := checkLocale(cfg.Locale); err != nil {
+		return nil, err
+	}
+	if cfg.NudgeCooldown < 0 {
+		return nil, errors.New("nudge cooldown must not be negative")
+	}
+	t, err := parseNudgeTemplate(cfg.NudgeTemplate, cfg.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "initializing %s checker", cfg.GitProvider)
+	}
+	nudger, err := newGitMergeRequestNudger(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "initializing %s nudger", cfg.GitProvider)
+	}
+
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	var nudges []Nudge
+	now := time.Now()
+	for _, project := range projects {
+		mr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+			State:        MergeRequestStateOpened,
+			TargetBranch: cfg.TargetBranch,
+		})
+		if err != nil {
+			return nudges, errors.Wrapf(err, "listing merge requests of %s", project)
+		}
+
+		mr, delays := probe.selectMergeRequests(mr)
+		for i, m := range mr {
+			if now.Sub(m.UpdatedAt) < delays[i].Warning {
+				continue
+			}
+			nudges = append(nudges, nudge(cfg, nudger, t, m, now))
+		}
+	}
+
+	return nudges, nil
+}
+
+// nudge comments and labels m, unless it was nudged within the cooldown
+func nudge(cfg ProbeConfig, nudger GitMergeRequestNudger, t *template.Template, m MergeRequest, now time.Time) Nudge {
+	n := Nudge{
+		MergeRequest: m,
+		LastActivity: now.Sub(m.UpdatedAt),
+	}
+
+	comments, err := nudger.ListComments(m.Project, m.IID)
+	if err != nil {
+		n.Err = errors.Wrap(err, "listing comments")
+		return n
+	}
+	for _, c := range comments {
+		if strings.Contains(c.Body, nudgeMarker) && now.Sub(c.CreatedAt) < cfg.NudgeCooldown && c.CreatedAt.After(n.PreviousNudge) {
+			n.PreviousNudge = c.CreatedAt
+		}
+	}
+	if n.Skipped() {
+		log.WithFields(log.Fields{
+			"project":        m.Project,
+			"merge-request":  m.IID,
+			"previous-nudge": n.PreviousNudge,
+		}).Debug("merge request nudged within the cooldown, skipping")
+		return n
+	}
+
+	if n.Comment, err = executeNudgeTemplate(t, newMergeRequestTemplateData(m, now)); err != nil {
+		n.Err = err
+		return n
+	}
+	if cfg.NudgeLabel != "" && !m.HasLabel(cfg.NudgeLabel) {
+		n.Labels = []string{cfg.NudgeLabel}
+	}
+
+	if cfg.DryRun {
+		return n
+	}
+
+	if err := nudger.AddComment(m.Project, m.IID, n.Comment+"\n\n"+nudgeMarker); err != nil {
+		n.Err = errors.Wrap(err, "adding comment")
+		return n
+	}
+	if len(n.Labels) > 0 {
+		if err := nudger.AddLabels(m.Project, m.IID, n.Labels); err != nil {
+			n.Err = errors.Wrap(err, "adding labels")
+			return n
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"project":       m.Project,
+		"merge-request": m.IID,
+		"labels":        n.Labels,
+	}).Debug("merge request nudged successfully")
+	return n
+}
+
+// parseNudgeTemplate parses the comment template and checks that it renders
+func parseNudgeTemplate(text, locale string) (*template.Template, error) {
+	t, err := template.New("nudge").Funcs(templateFuncs(locale)).Option("missingkey=error").Parse(localize(locale, text))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing nudge template")
+	}
+
+	now := time.Now()
+	if _, err := executeNudgeTemplate(t, newMergeRequestTemplateData(sampleMergeRequest(now), now)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func executeNudgeTemplate(t *template.Template, data MergeRequestTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "executing %s template", t.Name())
+	}
+	comment := strings.TrimSpace(buf.String())
+	if comment == "" {
+		return "", fmt.Errorf("%s template rendered an empty comment", t.Name())
+	}
+	return comment, nil
+}
@@ -0,0 +1,131 @@
+package nagios
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNudger serves comments and records the calls changing merge requests
+type fakeNudger struct {
+	comments      []Comment
+	listErr       error
+	addCommentErr error
+
+	addedComments []string
+	addedLabels   [][]string
+}
+
+func (f *fakeNudger) ListComments(project string, iid int) ([]Comment, error) {
+	return f.comments, f.listErr
+}
+
+func (f *fakeNudger) AddComment(project string, iid int, body string) error {
+	if f.addCommentErr != nil {
+		return f.addCommentErr
+	}
+	f.addedComments = append(f.addedComments, body)
+	return nil
+}
+
+func (f *fakeNudger) AddLabels(project string, iid int, labels []string) error {
+	f.addedLabels = append(f.addedLabels, labels)
+	return nil
+}
+
+func TestNudge(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	tmpl, err := parseNudgeTemplate(DefaultNudgeTemplate, LocaleEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const comment = "@alice this merge request has had no activity for 3d. Is it still relevant?"
+	nudged := func(at time.Time) Comment {
+		return Comment{Author: "bot", Body: "earlier nudge\n\n" + nudgeMarker, CreatedAt: at}
+	}
+
+	tests := []struct {
+		name              string
+		labels            []string
+		dryRun            bool
+		nudger            fakeNudger
+		wantPrevious      time.Time
+		wantAddedComments []string
+		wantAddedLabels   [][]string
+		wantLabels        []string
+		wantErr           string
+	}{
+		{
+			name:              "never nudged",
+			nudger:            fakeNudger{comments: []Comment{{Author: "bob", Body: "LGTM", CreatedAt: now.Add(-time.Hour)}}},
+			wantAddedComments: []string{comment + "\n\n" + nudgeMarker},
+			wantAddedLabels:   [][]string{{"stale"}},
+			wantLabels:        []string{"stale"},
+		},
+		{
+			name:         "nudged within the cooldown",
+			nudger:       fakeNudger{comments: []Comment{nudged(now.Add(-100 * time.Hour)), nudged(now.Add(-30 * time.Hour))}},
+			wantPrevious: now.Add(-30 * time.Hour),
+		},
+		{
+			name:              "nudged before the cooldown",
+			nudger:            fakeNudger{comments: []Comment{nudged(now.Add(-72 * time.Hour))}},
+			wantAddedComments: []string{comment + "\n\n" + nudgeMarker},
+			wantAddedLabels:   [][]string{{"stale"}},
+			wantLabels:        []string{"stale"},
+		},
+		{
+			name:              "label already present",
+			labels:            []string{"backend", "stale"},
+			wantAddedComments: []string{comment + "\n\n" + nudgeMarker},
+		},
+		{
+			name:       "dry run",
+			dryRun:     true,
+			wantLabels: []string{"stale"},
+		},
+		{
+			name:    "listing comments fails",
+			nudger:  fakeNudger{listErr: errors.New("502 Bad Gateway")},
+			wantErr: "listing comments: 502 Bad Gateway",
+		},
+		{
+			name:       "adding the comment fails",
+			nudger:     fakeNudger{addCommentErr: errors.New("403 Forbidden")},
+			wantLabels: []string{"stale"},
+			wantErr:    "adding comment: 403 Forbidden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ProbeConfig{NudgeCooldown: 48 * time.Hour, NudgeLabel: "stale", DryRun: tt.dryRun}
+			m := MergeRequest{IID: 1, Project: "group/project", Author: "alice", Labels: tt.labels, UpdatedAt: now.Add(-72 * time.Hour)}
+
+			n := nudge(cfg, &tt.nudger, tmpl, m, now)
+			if tt.wantErr == "" && n.Err != nil {
+				t.Errorf("nudge() error = %v", n.Err)
+			}
+			if tt.wantErr != "" && (n.Err == nil || !strings.Contains(n.Err.Error(), tt.wantErr)) {
+				t.Errorf("nudge() error = %v, want %q", n.Err, tt.wantErr)
+			}
+			if !n.PreviousNudge.Equal(tt.wantPrevious) || n.Skipped() != !tt.wantPrevious.IsZero() {
+				t.Errorf("PreviousNudge = %s, want %s", n.PreviousNudge, tt.wantPrevious)
+			}
+			if !reflect.DeepEqual(n.Labels, tt.wantLabels) {
+				t.Errorf("Labels = %v, want %v", n.Labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(tt.nudger.addedComments, tt.wantAddedComments) {
+				t.Errorf("added comments %q, want %q", tt.nudger.addedComments, tt.wantAddedComments)
+			}
+			if !reflect.DeepEqual(tt.nudger.addedLabels, tt.wantAddedLabels) {
+				t.Errorf("added labels %v, want %v", tt.nudger.addedLabels, tt.wantAddedLabels)
+			}
+			if !n.Skipped() && tt.wantErr == "" && n.Comment != comment {
+				t.Errorf("Comment = %q, want %q", n.Comment, comment)
+			}
+		})
+	}
+}
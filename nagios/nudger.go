@@ -0,0 +1,35 @@
+package nagios
+
+import (
+	"fmt"
+	"time"
+)
+
+// GitMergeRequestNudger acts on merge requests on behalf of the nudge command
+type GitMergeRequestNudger interface {
+	// ListComments returns the comments of the merge request, oldest first
+	ListComments(project string, iid int) ([]Comment, error)
+	// AddComment posts body as a new comment of the merge request
+	AddComment(project string, iid int, body string) error
+	// AddLabels adds labels to the merge request, keeping the existing ones
+	AddLabels(project string, iid int, labels []string) error
+}
+
+// Comment is a comment of a merge request (Gitlab note or Github issue comment)
+type Comment struct {
+	Author    string
+	Body      string
+	CreatedAt time.Time
+}
+
+// newGitMergeRequestNudger returns the GitMergeRequestNudger implementation
+// matching the configured git provider
+func newGitMergeRequestNudger(cfg ProbeConfig) (GitMergeRequestNudger, error) {
+	switch cfg.GitProvider {
+	case GitlabGitProvider:
+		return newGitlabMergeRequestNudger(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient())
+	case GithubGitProvider:
+		return newGithubMergeRequestNudger(cfg.APIEndpoint, cfg.APIToken, cfg.providerHTTPClient())
+	}
+	return nil, fmt.Errorf("git provider %s is not supported yet", cfg.GitProvider)
+}
@@ -0,0 +1,444 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// PassiveTemplateData is what the host and service name templates
+// of the passive results are executed with
+type PassiveTemplateData struct {
+	Project   string // full path of the project, e.g. group/subgroup/project
+	Name      string // last element of the project path
+	Namespace string // project path without its name, empty if none
+	Mode      string
+}
+
+// passiveResult is the check result of a project, as submitted to the monitoring
+type passiveResult struct {
+	Host     string
+	Service  string
+	Status   nagiosplugin.Status
+	Output   string // status line and long output
+	Perfdata []string
+}
+
+// passiveSubmitter submits passive check results to the monitoring
+type passiveSubmitter interface {
+	Name() string
+	Submit(ctx context.Context, r passiveResult) error
+}
+
+// PassiveSubmission is the outcome of the submission of the result of a project
+type PassiveSubmission struct {
+	Project string
+	Host    string
+	Service string
+	Status  string
+	Err     error
+}
+
+// SubmitPassiveResults evaluates the configured check mode on every project
+// of the configured group, list of projects or project, and submits one
+// passive result per project
+func SubmitPassiveResults(cfg ProbeConfig) ([]PassiveSubmission, error) {
+	submitters, err := newPassiveSubmitters(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostTemplate, serviceTemplate, err := parsePassiveTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSupportedGitProvider(cfg.GitProvider) {
+		return nil, fmt.Errorf("git provider %q is not supported", cfg.GitProvider)
+	}
+	if cfg.Mode == ReviewerLoadMode {
+		return nil, fmt.Errorf("%s mode already spans several projects and cannot be submitted per project", cfg.Mode)
+	}
+	// every project is checked with the same configuration
+	probe := nagiosProbe{cfg: passiveProjectConfig(cfg, "validation")}
+	if err := probe.init(); err != nil {
+		return nil, err
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "initializing %s checker", cfg.GitProvider)
+	}
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []PassiveSubmission
+	for _, project := range projects {
+		data := newPassiveTemplateData(project, cfg.Mode)
+		s := PassiveSubmission{Project: project}
+
+		if s.Host, err = executePassiveTemplate(hostTemplate, data); err != nil {
+			s.Err = err
+			submissions = append(submissions, s)
+			continue
+		}
+		if s.Service, err = executePassiveTemplate(serviceTemplate, data); err != nil {
+			s.Err = err
+			submissions = append(submissions, s)
+			continue
+		}
+
+		c := evaluateProject(passiveProjectConfig(cfg, project))
+		s.Status = c.status.String()
+		r := passiveResult{
+			Host:     s.Host,
+			Service:  s.Service,
+			Status:   c.status,
+			Output:   c.pluginOutput(),
+			Perfdata: c.rendered,
+		}
+
+		log.WithFields(log.Fields{
+			"project": project,
+			"host":    r.Host,
+			"service": r.Service,
+			"status":  r.Status,
+		}).Debug("project evaluated")
+
+		// a failing target must not prevent the others from getting the result
+		var failures []string
+		for _, submitter := range submitters {
+			if err := submitPassiveResult(submitter, r, cfg.Timeout); err != nil {
+				failures = append(failures, errors.Wrapf(err, "submitting to %s", submitter.Name()).Error())
+			}
+		}
+		if len(failures) > 0 {
+			s.Err = errors.New(strings.Join(failures, "; "))
+		}
+		submissions = append(submissions, s)
+	}
+
+	return submissions, nil
+}
+
+// passiveProjectConfig returns the configuration checking the single project
+func passiveProjectConfig(cfg ProbeConfig, project string) ProbeConfig {
+	cfg.Project = project
+	cfg.Projects = nil
+	cfg.Group = ""
+	return cfg
+}
+
+func newPassiveTemplateData(project, mode string) PassiveTemplateData {
+	namespace := path.Dir(project)
+	if namespace == "." {
+		namespace = ""
+	}
+	return PassiveTemplateData{
+		Project:   project,
+		Name:      path.Base(project),
+		Namespace: namespace,
+		Mode:      mode,
+	}
+}
+
+func parsePassiveTemplates(cfg ProbeConfig) (*template.Template, *template.Template, error) {
+	host, err := parseNameTemplate("host", cfg.PassiveHostTemplate, cfg.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	service, err := parseNameTemplate("service", cfg.PassiveServiceTemplate, cfg.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return host, service, nil
+}
+
+// parseNameTemplate parses a template naming hosts or services after a project
+func parseNameTemplate(name, text, mode string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s template", name)
+	}
+
+	// unknown fields are only reported on execution
+	if _, err := executePassiveTemplate(t, newPassiveTemplateData("group/project", mode)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func executePassiveTemplate(t *template.Template, data PassiveTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "executing %s template", t.Name())
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("%s template rendered an empty name for %s", t.Name(), data.Project)
+	}
+	return name, nil
+}
+
+// newCheckSubmitter returns the NRDP submitter of a single check
+// and the host and service names its result is submitted for
+func newCheckSubmitter(cfg ProbeConfig) (passiveSubmitter, string, string, error) {
+	if cfg.NRDPURL == "" {
+		return nil, "", "", errors.New("an NRDP URL is required")
+	}
+	submitter, err := newNRDPSubmitter(cfg, os.Stdout)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	hostTemplate, serviceTemplate, err := parsePassiveTemplates(cfg)
+	if err != nil {
+		return nil, "", "", err
+	}
+	data := newPassiveTemplateData(sinkProject(cfg), cfg.Mode)
+	host, err := executePassiveTemplate(hostTemplate, data)
+	if err != nil {
+		return nil, "", "", err
+	}
+	service, err := executePassiveTemplate(serviceTemplate, data)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return submitter, host, service, nil
+}
+
+// evaluateProject runs the check of cfg within the configured timeout.
+// On timeout, the requests of the abandoned evaluation are canceled, so that
+// it quickly fails instead of querying the provider along the next projects.
+func evaluateProject(cfg ProbeConfig) *Check {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	cfg = cfg.withContext(ctx)
+
+	done := make(chan *Check, 1)
+	go func() {
+		done <- evaluateCaptured(func(c *Check) {
+			probe := nagiosProbe{cfg: cfg, nagCheck: c}
+			probe.Run()
+		})
+	}()
+
+	select {
+	case c := <-done:
+		return c
+	case <-ctx.Done():
+		return evaluateCaptured(func(c *Check) {
+			c.Unknownf("timeout after %s", cfg.Timeout)
+		})
+	}
+}
+
+func submitPassiveResult(submitter passiveSubmitter, r passiveResult, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return submitter.Submit(ctx, r)
+}
+
+// newPassiveSubmitters returns the submitters enabled in cfg
+func newPassiveSubmitters(cfg ProbeConfig) ([]passiveSubmitter, error) {
+	var submitters []passiveSubmitter
+
+	if cfg.Icinga2URL != "" {
+		s, err := newIcinga2Submitter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		submitters = append(submitters, s)
+	}
+
+	if cfg.CommandFile != "" {
+		submitters = append(submitters, commandFileSubmitter{path: cfg.CommandFile})
+	}
+
+	if cfg.NRDPURL != "" {
+		s, err := newNRDPSubmitter(cfg, os.Stdout)
+		if err != nil {
+			return nil, err
+		}
+		submitters = append(submitters, s)
+	}
+
+	if len(submitters) == 0 {
+		return nil, errors.New("an Icinga2 API URL, a command file or an NRDP URL is required")
+	}
+	return submitters, nil
+}
+
+// icinga2Submitter submits through the process-check-result action of the Icinga2 API
+type icinga2Submitter struct {
+	url      string
+	user     string
+	password string
+	source   string
+	client   *http.Client
+}
+
+func newIcinga2Submitter(cfg ProbeConfig) (icinga2Submitter, error) {
+	s := icinga2Submitter{
+		url:      strings.TrimSuffix(cfg.Icinga2URL, "/") + "/v1/actions/process-check-result",
+		user:     cfg.Icinga2User,
+		password: cfg.Icinga2Password,
+		client:   http.DefaultClient,
+	}
+	s.source, _ = os.Hostname()
+
+	if cfg.Icinga2CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.Icinga2CAFile)
+		if err != nil {
+			return s, errors.Wrap(err, "reading Icinga2 CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return s, fmt.Errorf("no certificate found in Icinga2 CA file %s", cfg.Icinga2CAFile)
+		}
+		s.client = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		}
+	}
+
+	return s, nil
+}
+
+func (s icinga2Submitter) Name() string {
+	return "icinga2"
+}
+
+type icinga2CheckResult struct {
+	Type            string            `json:"type"`
+	Filter          string            `json:"filter"`
+	FilterVars      map[string]string `json:"filter_vars"`
+	ExitStatus      int               `json:"exit_status"`
+	PluginOutput    string            `json:"plugin_output"`
+	PerformanceData []string          `json:"performance_data,omitempty"`
+	CheckSource     string            `json:"check_source,omitempty"`
+}
+
+type icinga2ActionResponse struct {
+	Results []struct {
+		Code   float64 `json:"code"`
+		Status string  `json:"status"`
+	} `json:"results"`
+}
+
+func (s icinga2Submitter) Submit(ctx context.Context, r passiveResult) error {
+	body, err := json.Marshal(icinga2CheckResult{
+		Type: "Service",
+		// names are passed as variables so that they never need escaping
+		Filter:          "host.name==host_name && service.name==service_name",
+		FilterVars:      map[string]string{"host_name": r.Host, "service_name": r.Service},
+		ExitStatus:      int(r.Status),
+		PluginOutput:    r.Output,
+		PerformanceData: r.Perfdata,
+		CheckSource:     s.source,
+	})
+	if err != nil {
+		return errors.Wrap(err, "encoding check result")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "posting to %s", s.url)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("service %s!%s not found", r.Host, r.Service)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting to %s: %s: %s", s.url, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var actionResp icinga2ActionResponse
+	if err := json.Unmarshal(respBody, &actionResp); err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+	if len(actionResp.Results) == 0 {
+		return fmt.Errorf("service %s!%s not found", r.Host, r.Service)
+	}
+	for _, res := range actionResp.Results {
+		if res.Code != http.StatusOK {
+			return fmt.Errorf("service %s!%s: %s", r.Host, r.Service, res.Status)
+		}
+	}
+	return nil
+}
+
+// commandFileSubmitter writes PROCESS_SERVICE_CHECK_RESULT external commands
+// to the Nagios (or Icinga) command file
+type commandFileSubmitter struct {
+	path string
+}
+
+func (s commandFileSubmitter) Name() string {
+	return "command file"
+}
+
+// commandFileEscaper keeps an external command on a single line
+var commandFileEscaper = strings.NewReplacer("\n", `\n`, "|", "/")
+
+func (s commandFileSubmitter) Submit(ctx context.Context, r passiveResult) error {
+	output := commandFileEscaper.Replace(r.Output)
+	if len(r.Perfdata) > 0 {
+		output += "|" + strings.Join(r.Perfdata, " ")
+	}
+	command := fmt.Sprintf("[%d] PROCESS_SERVICE_CHECK_RESULT;%s;%s;%d;%s\n",
+		time.Now().Unix(), r.Host, r.Service, r.Status, output)
+
+	// opening a named pipe blocks until the monitoring reads it
+	done := make(chan error, 1)
+	go func() {
+		f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			done <- errors.Wrapf(err, "opening %s", s.path)
+			return
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(command); err != nil {
+			done <- errors.Wrapf(err, "writing to %s", s.path)
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "writing to %s", s.path)
+	}
+}
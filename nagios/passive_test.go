@@ -0,0 +1,219 @@
+package nagios
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// passiveTestConfig checks the merge requests of group/project on a Gitlab
+// stand-in, and submits the result to an Icinga2 stand-in
+func passiveTestConfig(url string) ProbeConfig {
+	return ProbeConfig{
+		APIEndpoint:             url,
+		GitProvider:             GitlabGitProvider,
+		Project:                 "group/project",
+		Mode:                    MergeRequestsMode,
+		Timeout:                 5 * time.Second,
+		WarningLastUpdateDelay:  6 * time.Hour,
+		CriticalLastUpdateDelay: 24 * time.Hour,
+		Locale:                  LocaleEnglish,
+		Icinga2URL:              url,
+		Icinga2User:             "root",
+		Icinga2Password:         "icinga",
+		PassiveHostTemplate:     "gitlab",
+		PassiveServiceTemplate:  "merge requests {{ .Name }}",
+	}
+}
+
+func TestSubmitPassiveResultsIcinga2(t *testing.T) {
+	var (
+		posted   icinga2CheckResult
+		user     string
+		password string
+	)
+	icinga := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[{"code":200.0,"status":"Successfully processed check result for object 'gitlab!merge requests project'."}]}`))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v4/":
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+		case strings.HasSuffix(r.URL.Path, "/merge_requests"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+		case r.URL.Path == "/v1/actions/process-check-result" && r.Method == http.MethodPost:
+			user, password, _ = r.BasicAuth()
+			if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+				t.Errorf("decoding check result: %s", err)
+			}
+			icinga(w, r)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	submissions, err := SubmitPassiveResults(passiveTestConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submissions) != 1 {
+		t.Fatalf("got %d submissions, want 1", len(submissions))
+	}
+	s := submissions[0]
+	if s.Err != nil || s.Host != "gitlab" || s.Service != "merge requests project" || s.Status != "OK" {
+		t.Errorf("submission = %+v", s)
+	}
+
+	if user != "root" || password != "icinga" {
+		t.Errorf("basic auth = %q/%q, want root/icinga", user, password)
+	}
+	if posted.Type != "Service" {
+		t.Errorf("type = %q, want Service", posted.Type)
+	}
+	if posted.Filter != "host.name==host_name && service.name==service_name" {
+		t.Errorf("filter = %q", posted.Filter)
+	}
+	wantVars := map[string]string{"host_name": "gitlab", "service_name": "merge requests project"}
+	if !reflect.DeepEqual(posted.FilterVars, wantVars) {
+		t.Errorf("filter_vars = %v, want %v", posted.FilterVars, wantVars)
+	}
+	if posted.ExitStatus != 0 {
+		t.Errorf("exit_status = %d, want 0", posted.ExitStatus)
+	}
+	if posted.PluginOutput != "OK: No opened merge requests" {
+		t.Errorf("plugin_output = %q", posted.PluginOutput)
+	}
+	perfdata := strings.Join(posted.PerformanceData, " ")
+	for _, want := range []string{"'total_duration'=", "'opened_merge_requests'=0;;;;"} {
+		if !strings.Contains(perfdata, want) {
+			t.Errorf("performance_data %v does not contain %s", posted.PerformanceData, want)
+		}
+	}
+
+	// the service does not exist
+	for name, handler := range map[string]http.HandlerFunc{
+		"not found": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":404.0,"status":"No objects found."}`))
+		},
+		"empty results": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"results":[]}`))
+		},
+	} {
+		icinga = handler
+		submissions, err := SubmitPassiveResults(passiveTestConfig(srv.URL))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		want := "submitting to icinga2: service gitlab!merge requests project not found"
+		if err := submissions[0].Err; err == nil || err.Error() != want {
+			t.Errorf("%s: error = %v, want %q", name, err, want)
+		}
+	}
+}
+
+func TestIcinga2SubmitterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+		body string
+		want string
+	}{
+		{"object error", http.StatusOK, `{"results":[{"code":500.0,"status":"Attribute 'exit_status' must be set."}]}`, "service h!s: Attribute 'exit_status' must be set."},
+		{"server error", http.StatusInternalServerError, `oops`, "500 Internal Server Error: oops"},
+		{"invalid response", http.StatusOK, `<html>`, "decoding response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s, err := newIcinga2Submitter(ProbeConfig{Icinga2URL: srv.URL + "/"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Submit(context.Background(), passiveResult{Host: "h", Service: "s", Status: nagiosplugin.CRITICAL})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Submit() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandFileSubmitter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "nagios.cmd")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := commandFileSubmitter{path: file}
+	r := passiveResult{
+		Host:     "gitlab",
+		Service:  "merge requests project",
+		Status:   nagiosplugin.WARNING,
+		Output:   "WARNING: Merge request 12 last activity was 7h ago\nMerge requests that have conflicts: 12|13",
+		Perfdata: []string{"'opened_merge_requests'=2;;;;", "'oldest_merge_request'=25200s;;;;"},
+	}
+	before := time.Now().Unix()
+	for i := 0; i < 2; i++ {
+		if err := s.Submit(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Fatalf("command file = %q, want 2 appended lines", data)
+	}
+
+	re := regexp.MustCompile(`^\[(\d+)\] (.*)\n$`)
+	want := `PROCESS_SERVICE_CHECK_RESULT;gitlab;merge requests project;1;` +
+		`WARNING: Merge request 12 last activity was 7h ago\nMerge requests that have conflicts: 12/13` +
+		`|'opened_merge_requests'=2;;;; 'oldest_merge_request'=25200s;;;;`
+	for _, line := range lines[:2] {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("line %q is not an external command", line)
+		}
+		if ts, _ := strconv.ParseInt(m[1], 10, 64); ts < before || ts > time.Now().Unix() {
+			t.Errorf("timestamp %s is not the submission time", m[1])
+		}
+		if m[2] != want {
+			t.Errorf("command = %q\nwant      %q", m[2], want)
+		}
+	}
+}
+
+func TestCommandFileSubmitterMissingFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing", "nagios.cmd")
+	err := commandFileSubmitter{path: file}.Submit(context.Background(), passiveResult{Host: "h", Service: "s"})
+	if err == nil || !os.IsNotExist(errors.Cause(err)) {
+		t.Errorf("Submit() error = %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,15 @@
+package nagios
+
+import "strings"
+
+// sanitizePerfLabel replaces the characters that are not safe
+// in a perfdata label (quotes, equal signs, spaces, ...) by an underscore
+func sanitizePerfLabel(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, label)
+}
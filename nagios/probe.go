@@ -1,6 +1,11 @@
 package nagios
 
 import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,7 +19,50 @@ const (
 )
 
 func ProbeCobraAdapter(cmd *cobra.Command, args []string, cfg ProbeConfig) {
-	checker := cmd.Context().Value(CheckerContextKey).(*nagiosplugin.Check)
+	checker := cmd.Context().Value(CheckerContextKey).(*Check)
+	if cfg.Output != "" {
+		if err := checker.SetOutput(cfg.Output); err != nil {
+			checker.Unknownf("invalid configuration: %s", err)
+		}
+	}
+
+	// sinks run once the check is evaluated, within the global timeout
+	sinks, err := newPerfdataSinks(cfg)
+	if err != nil {
+		checker.Unknownf("invalid configuration: %s", err)
+	}
+	checker.setSinks(sinks, sinkProject(cfg), cfg.Mode)
+	checker.setDeadline(time.Now().Add(cfg.Timeout))
+
+	notifier, err := newWebhookNotifier(cfg)
+	if err != nil {
+		checker.Unknownf("invalid configuration: %s", err)
+	}
+	checker.setNotifier(notifier)
+
+	if cfg.Output == OutputNRDP {
+		submitter, host, service, err := newCheckSubmitter(cfg)
+		if err != nil {
+			checker.Unknownf("invalid configuration: %s", err)
+		}
+		checker.setNames(host, service)
+		checker.setSubmitter(submitter, cfg.Timeout)
+	}
+
+	if cfg.Output == OutputCheckmk {
+		service, err := checkmkService(cfg, sinkProject(cfg))
+		if err != nil {
+			checker.setNames("", fmt.Sprintf("git %s", cfg.Mode))
+			checker.Unknownf("invalid configuration: %s", err)
+		}
+		checker.setNames("", service)
+
+		// each project has its own line, checked within its own timeout
+		if isCheckmkMultiProject(cfg) {
+			printCheckmkProjects(os.Stdout, cfg, checker)
+			os.Exit(0)
+		}
+	}
 
 	probe := nagiosProbe{
 		cfg:      cfg,
@@ -48,84 +96,421 @@ func ProbeCobraAdapter(cmd *cobra.Command, args []string, cfg ProbeConfig) {
 type nagiosProbe struct {
 	Hostname string
 	cfg      ProbeConfig
-	nagCheck *nagiosplugin.Check
+	nagCheck *Check
+
+	conflictsStatus       nagiosplugin.Status
+	failedPipelinesStatus nagiosplugin.Status
+	rules                 []compiledMergeRequestRule
+	filter                *Filter
+	messages              messageTemplates
+
+	metricThresholds map[string]thresholds
+	checkedMetrics   map[string]bool
+
+	// count and reviewer-load modes
+	perUserThresholds thresholds
+
+	// abandoned mode
+	botPattern *regexp.Regexp
+
+	start time.Time
+}
+
+// ValidateConfig checks cfg the same way the probe does before contacting the git provider
+func ValidateConfig(cfg ProbeConfig) error {
+	if !isSupportedGitProvider(cfg.GitProvider) {
+		return fmt.Errorf("git provider %q is not supported", cfg.GitProvider)
+	}
+	if cfg.Output != "" {
+		if err := NewCheck().SetOutput(cfg.Output); err != nil {
+			return err
+		}
+	}
+	if _, err := newPerfdataSinks(cfg); err != nil {
+		return err
+	}
+	if _, err := newWebhookNotifier(cfg); err != nil {
+		return err
+	}
+	if cfg.NudgeTemplate != "" {
+		if _, err := parseNudgeTemplate(cfg.NudgeTemplate, cfg.Locale); err != nil {
+			return err
+		}
+	}
+	if cfg.Output == OutputNRDP {
+		if _, _, _, err := newCheckSubmitter(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.Output == OutputCheckmk {
+		if _, err := checkmkService(cfg, sinkProject(cfg)); err != nil {
+			return err
+		}
+	}
+	probe := nagiosProbe{cfg: cfg}
+	return probe.init()
+}
+
+// initSelection prepares the filter and the rules selecting the merge requests to evaluate
+func (c *nagiosProbe) initSelection() error {
+	var err error
+
+	if c.cfg.APIEndpoint == "" {
+		return errors.New("a host (API endpoint) is required")
+	}
+
+	if c.cfg.Filter != "" {
+		if c.filter, err = ParseFilter(c.cfg.Filter); err != nil {
+			return errors.Wrap(err, "parsing filter")
+		}
+	}
+
+	global := mergeRequestDelays{Warning: c.cfg.WarningLastUpdateDelay, Critical: c.cfg.CriticalLastUpdateDelay}
+	if c.rules, err = compileRules(c.cfg.Rules, global); err != nil {
+		return errors.Wrap(err, "compiling rules")
+	}
+
+	return nil
 }
 
 func (c *nagiosProbe) init() error {
+	var err error
+
+	if err := checkLocale(c.cfg.Locale); err != nil {
+		return err
+	}
+
+	if c.cfg.CheckConflicts {
+		if c.conflictsStatus, err = parseStatus(c.cfg.ConflictsSeverity); err != nil {
+			return errors.Wrap(err, "parsing conflicts severity")
+		}
+	}
+
+	if c.cfg.CheckFailedPipelines {
+		if c.failedPipelinesStatus, err = parseStatus(c.cfg.FailedPipelinesSeverity); err != nil {
+			return errors.Wrap(err, "parsing failed pipelines severity")
+		}
+	}
+
+	if err := c.initSelection(); err != nil {
+		return err
+	}
+
+	if err := c.initMetrics(); err != nil {
+		return err
+	}
+
+	if c.messages, err = parseMessageTemplates(c.cfg); err != nil {
+		return err
+	}
+
+	switch c.cfg.Mode {
+	case MergeRequestsMode, CountMode, ThroughputMode, AbandonedMode, BranchesMode, IssuesMode:
+		if c.cfg.Project == "" {
+			return errors.New("a project is required")
+		}
+	case ReviewerLoadMode:
+		if c.cfg.Project == "" && len(c.cfg.Projects) == 0 && c.cfg.Group == "" {
+			return errors.New("a project, a list of projects or a group is required")
+		}
+	default:
+		return fmt.Errorf("unsupported mode %q", c.cfg.Mode)
+	}
+
+	if (c.cfg.Mode == ThroughputMode || c.cfg.Mode == AbandonedMode) && c.cfg.Lookback <= 0 {
+		return errors.New("lookback must be positive")
+	}
+
+	for _, pattern := range c.cfg.ExcludeBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid excluded branches pattern %q", pattern)
+		}
+	}
+
+	if c.cfg.Mode == AbandonedMode {
+		if c.botPattern, err = regexp.Compile(c.cfg.BotPattern); err != nil {
+			return errors.Wrap(err, "compiling bot pattern")
+		}
+	}
+
+	if c.cfg.Mode == CountMode || c.cfg.Mode == ReviewerLoadMode {
+		if err := c.initCountMode(); err != nil {
+			return errors.Wrapf(err, "initializing %s mode", c.cfg.Mode)
+		}
+	}
+
 	return nil
 }
 
 func (c nagiosProbe) Run() {
+	c.start = time.Now()
+
 	if err := c.init(); err != nil {
 		c.nagCheck.Exitf(nagiosplugin.UNKNOWN, errors.Wrap(err, "initializing nagios probe").Error())
+		return
 	}
 
-	// TODO:
-	if c.cfg.GitProvider != GitlabGitProvider {
+	if !isSupportedGitProvider(c.cfg.GitProvider) {
 		c.nagCheck.Criticalf("git provider %s is not supported yet", c.cfg.GitProvider)
+		return
+	}
+
+	if c.cfg.Mode == IssuesMode {
+		issueChecker, err := newGitIssueChecker(c.cfg)
+		if err != nil {
+			c.nagCheck.Unknownf("fail to initialize %s checker: %s", c.cfg.GitProvider, err)
+			return
+		}
+		c.checkIssues(issueChecker)
+		return
 	}
 
-	mrChecker, err := newGitlabProjectMRChecker(c.cfg.APIEndpoint, c.cfg.APIToken)
+	mrChecker, err := newGitMergeRequestChecker(c.cfg, c.mrCheckerOptions())
 	if err != nil {
-		c.nagCheck.Unknownf("fail to initialize gitlab checker: %s", err)
+		c.nagCheck.Unknownf("fail to initialize %s checker: %s", c.cfg.GitProvider, err)
+		return
 	}
 
-	c.checkMergeRequests(mrChecker)
+	switch c.cfg.Mode {
+	case CountMode:
+		c.checkMergeRequestCounts(mrChecker)
+	case ReviewerLoadMode:
+		c.checkReviewerLoad(mrChecker)
+	case ThroughputMode:
+		c.checkThroughput(mrChecker)
+	case AbandonedMode:
+		c.checkAbandoned(mrChecker)
+	case BranchesMode:
+		c.checkBranches(mrChecker)
+	default:
+		c.checkMergeRequests(mrChecker)
+	}
 }
 
 func (c nagiosProbe) Finish() {
 	c.nagCheck.Finish()
 }
 
-func (c nagiosProbe) checkMergeRequests(mrChecker GitMergeRequestChecker) {
-	start := time.Now()
-
-	mr, err := mrChecker.CheckMergeRequests(c.cfg.Project, c.cfg.TargetBranch)
+// fetchMergeRequests lists the opened merge requests of the projects, drops the ones
+// filtered out or ignored by a rule, and returns the delays each remaining
+// merge request must be evaluated against
+func (c nagiosProbe) fetchMergeRequests(mrChecker GitMergeRequestChecker, projects ...string) ([]MergeRequest, []mergeRequestDelays, error) {
+	mr, delays, err := c.listMergeRequests(mrChecker, ListMergeRequestsOptions{
+		State:        MergeRequestStateOpened,
+		TargetBranch: c.cfg.TargetBranch,
+	}, projects...)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error":         err,
-			"project":       c.cfg.Project,
-			"api-endpoint":  c.cfg.APIEndpoint,
-			"target-branch": c.cfg.TargetBranch,
-		}).Error("fail to check for merge requests")
-		c.nagCheck.Criticalf("fail to check for merge requests: %s", err)
+		return nil, nil, err
+	}
+
+	c.addTotalDurationPerfDatum()
+
+	return mr, delays, nil
+}
+
+// listMergeRequests lists the merge requests of the projects matching opts
+// and drops the ones filtered out or ignored by a rule
+func (c nagiosProbe) listMergeRequests(mrChecker GitMergeRequestChecker, opts ListMergeRequestsOptions, projects ...string) ([]MergeRequest, []mergeRequestDelays, error) {
+	var mr []MergeRequest
+	for _, project := range projects {
+		pmr, err := mrChecker.ListMergeRequests(project, opts)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":         err,
+				"project":       project,
+				"api-endpoint":  c.cfg.APIEndpoint,
+				"target-branch": opts.TargetBranch,
+				"state":         opts.State,
+			}).Error("fail to check for merge requests")
+			return nil, nil, errors.Wrapf(err, "fail to check for merge requests of %s", project)
+		}
+		mr = append(mr, pmr...)
 	}
 
 	log.WithFields(log.Fields{
 		"merge-requests": mr,
 	}).Debug("merge requests fetched successfully")
 
-	durationValue, err := nagiosplugin.NewFloatPerfDatumValue(time.Since(start).Seconds())
+	evaluated, delays := c.selectMergeRequests(mr)
+	return evaluated, delays, nil
+}
+
+// selectMergeRequests drops the merge requests filtered out or ignored by a rule
+// and returns the delays each remaining merge request must be evaluated against
+func (c nagiosProbe) selectMergeRequests(mr []MergeRequest) ([]MergeRequest, []mergeRequestDelays) {
+	// merge requests filtered out or ignored by a rule are not evaluated at all
+	var evaluated []MergeRequest
+	var delays []mergeRequestDelays
+	now := time.Now()
+	for _, cmr := range mr {
+		if c.filter != nil && !c.filter.Match(cmr, now) {
+			log.WithFields(log.Fields{
+				"merge-request": cmr.IID,
+				"filter":        c.filter,
+			}).Debug("merge request filtered out")
+			continue
+		}
+
+		d := c.delaysFor(cmr)
+		if d.Ignore {
+			continue
+		}
+		evaluated = append(evaluated, cmr)
+		delays = append(delays, d)
+	}
+
+	return evaluated, delays
+}
+
+// addTotalDurationPerfDatum reports the time spent since the probe started
+func (c nagiosProbe) addTotalDurationPerfDatum() {
+	durationValue, err := nagiosplugin.NewFloatPerfDatumValue(time.Since(c.start).Seconds())
 	if err != nil {
 		c.nagCheck.Exitf(nagiosplugin.UNKNOWN, errors.Wrap(err, "creating perfdata").Error())
+		return
 	}
 	c.nagCheck.AddPerfDatum("total_duration", "s", durationValue, nil, nil, nil, nil)
+}
+
+func (c nagiosProbe) checkMergeRequests(mrChecker GitMergeRequestChecker) {
+	mr, delays, err := c.fetchMergeRequests(mrChecker, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
 
-	totalMr, _ := nagiosplugin.NewFloatPerfDatumValue(float64(len(mr)))
-	c.nagCheck.AddPerfDatum("opened_merge_requests", "", totalMr, nil, nil, nil, nil)
+	defaultMetrics := append([]string{OpenedMergeRequestsMetric, OldestMergeRequestMetric}, ageDistributionMetrics...)
+	var longOutput []string
+	if c.cfg.CheckConflicts {
+		defaultMetrics = append(defaultMetrics, ConflictingMergeRequestsMetric)
+		longOutput = append(longOutput, c.checkMergeRequestsCondition(mr, localize(c.cfg.Locale, "have conflicts"), c.conflictsStatus, func(m MergeRequest) bool {
+			return m.HasConflicts
+		})...)
+	}
+	if c.cfg.CheckFailedPipelines {
+		defaultMetrics = append(defaultMetrics, FailedPipelineMergeRequestsMetric)
+		longOutput = append(longOutput, c.checkMergeRequestsCondition(mr, localize(c.cfg.Locale, "have a failed pipeline"), c.failedPipelinesStatus, func(m MergeRequest) bool {
+			return m.HasFailedPipeline()
+		})...)
+	}
+
+	now := time.Now()
+	data := CheckTemplateData{Project: c.cfg.Project}
+	for i, cmr := range mr {
+		status, reasons := c.mergeRequestStatus(cmr, delays[i])
+		c.nagCheck.AddMergeRequest(cmr, status, reasons...)
+		data.MergeRequests = append(data.MergeRequests, newEvaluatedMergeRequestTemplateData(cmr, status.String(), reasons, now))
+	}
+
+	customLongOutput, err := executeMessageTemplate(c.messages.longOutput, data, false)
+	if err != nil {
+		c.nagCheck.Unknownf("%s", err)
+		return
+	}
+	if customLongOutput != "" {
+		longOutput = append(longOutput, customLongOutput)
+	}
+	if len(longOutput) > 0 {
+		c.nagCheck.AddLongPluginOutput(strings.Join(longOutput, "\n"))
+	}
+
+	c.checkMetrics(metricInput{opened: mr}, defaultMetrics...)
+
+	// the service state only comes from the metrics selected with --check-metric
+	if len(c.checkedMetrics) > 0 {
+		c.nagCheck.AddResult(nagiosplugin.OK, localize(c.cfg.Locale, "All checked metrics within thresholds"))
+		return
+	}
 
 	if len(mr) == 0 {
-		c.nagCheck.Exitf(nagiosplugin.OK, "No opened merge requests")
+		okSummary := localize(c.cfg.Locale, "No opened merge requests")
+		if c.messages.okSummary != nil {
+			if okSummary, err = executeMessageTemplate(c.messages.okSummary, CheckTemplateData{Project: c.cfg.Project}, true); err != nil {
+				c.nagCheck.Unknownf("%s", err)
+				return
+			}
+		}
+		c.nagCheck.Exitf(nagiosplugin.OK, "%s", okSummary)
 		return
 	}
 
-	c.nagCheck.AddResult(nagiosplugin.OK, "No merge requests too old")
+	okSummary := localize(c.cfg.Locale, "No merge requests too old")
+	if c.messages.okSummary != nil {
+		if okSummary, err = executeMessageTemplate(c.messages.okSummary, data, true); err != nil {
+			c.nagCheck.Unknownf("%s", err)
+			return
+		}
+	}
+	c.nagCheck.AddResult(nagiosplugin.OK, okSummary)
 
-	var oldestMrDuration time.Duration
-	for _, cmr := range mr {
-		tSinceLastUpdate := time.Since(cmr.UpdatedAt)
-		if tSinceLastUpdate >= c.cfg.CriticalLastUpdateDelay {
-			c.nagCheck.AddResultf(nagiosplugin.CRITICAL, "Merge request %d last activity was %s ago", cmr.ID, tSinceLastUpdate)
-		} else if tSinceLastUpdate >= c.cfg.WarningLastUpdateDelay {
-			c.nagCheck.AddResultf(nagiosplugin.WARNING, "Merge request %d last activity was %s ago", cmr.ID, tSinceLastUpdate)
+	for i, cmr := range mr {
+		tSinceLastUpdate := now.Sub(cmr.UpdatedAt)
+		status := nagiosplugin.OK
+		if tSinceLastUpdate >= delays[i].Critical {
+			status = nagiosplugin.CRITICAL
+		} else if tSinceLastUpdate >= delays[i].Warning {
+			status = nagiosplugin.WARNING
 		}
+		if status == nagiosplugin.OK {
+			continue
+		}
+
+		problem := fmt.Sprintf(localize(c.cfg.Locale, "Merge request %d last activity was %s ago"), cmr.ID, HumanizeDuration(tSinceLastUpdate, c.cfg.Locale))
+		if c.messages.problem != nil {
+			if problem, err = executeMessageTemplate(c.messages.problem, data.MergeRequests[i], true); err != nil {
+				c.nagCheck.Unknownf("%s", err)
+				return
+			}
+		}
+		c.nagCheck.AddResult(status, problem)
+	}
+}
+
+// mergeRequestStatus returns the individual status of a merge request
+// and the reasons of a status other than OK
+func (c nagiosProbe) mergeRequestStatus(m MergeRequest, delays mergeRequestDelays) (nagiosplugin.Status, []string) {
+	status := nagiosplugin.OK
+	var reasons []string
+	worsen := func(s nagiosplugin.Status, reason string) {
+		if s > status {
+			status = s
+		}
+		reasons = append(reasons, reason)
+	}
+
+	tSinceLastUpdate := time.Since(m.UpdatedAt)
+	if tSinceLastUpdate >= delays.Critical {
+		worsen(nagiosplugin.CRITICAL, fmt.Sprintf(localize(c.cfg.Locale, "last activity was %s ago"), HumanizeDuration(tSinceLastUpdate, c.cfg.Locale)))
+	} else if tSinceLastUpdate >= delays.Warning {
+		worsen(nagiosplugin.WARNING, fmt.Sprintf(localize(c.cfg.Locale, "last activity was %s ago"), HumanizeDuration(tSinceLastUpdate, c.cfg.Locale)))
+	}
+	if c.cfg.CheckConflicts && m.HasConflicts {
+		worsen(c.conflictsStatus, localize(c.cfg.Locale, "has conflicts"))
+	}
+	if c.cfg.CheckFailedPipelines && m.HasFailedPipeline() {
+		worsen(c.failedPipelinesStatus, localize(c.cfg.Locale, "has a failed pipeline"))
+	}
+
+	return status, reasons
+}
 
-		// keep track of our oldest merge-request for perfdata
-		if tSinceLastUpdate > oldestMrDuration {
-			oldestMrDuration = tSinceLastUpdate
+// checkMergeRequestsCondition counts the merge requests matching cond,
+// reports them with the given status unless metrics are selected with
+// --check-metric, and returns the long output lines listing the offending merge requests
+func (c nagiosProbe) checkMergeRequestsCondition(mr []MergeRequest, description string, status nagiosplugin.Status, cond func(MergeRequest) bool) []string {
+	var offending []string
+	for _, cmr := range mr {
+		if cond(cmr) {
+			offending = append(offending, fmt.Sprintf("%d", cmr.IID))
 		}
 	}
 
-	oldestMrPerfDatum, _ := nagiosplugin.NewFloatPerfDatumValue(oldestMrDuration.Seconds())
-	c.nagCheck.AddPerfDatum("oldest_merge_request", "s", oldestMrPerfDatum, nil, nil, nil, nil)
+	if len(offending) == 0 {
+		return nil
+	}
+
+	if len(c.checkedMetrics) == 0 {
+		c.nagCheck.AddResultf(status, localize(c.cfg.Locale, "%d merge requests %s"), len(offending), description)
+	}
+	return []string{fmt.Sprintf(localize(c.cfg.Locale, "Merge requests that %s: %s"), description, strings.Join(offending, ", "))}
 }
@@ -0,0 +1,56 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// checkReviewerLoad counts the merge requests per user across several projects
+// and alerts on the users holding too many of them
+func (c nagiosProbe) checkReviewerLoad(mrChecker GitMergeRequestChecker) {
+	role := c.cfg.PerUser
+	if role == "" {
+		role = ReviewerRole
+	}
+
+	projects, err := resolveProjects(c.cfg, mrChecker)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+	mr, _, err := c.fetchMergeRequests(mrChecker, projects...)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+
+	c.checkMetrics(metricInput{opened: mr}, OpenedMergeRequestsMetric)
+	for _, m := range mr {
+		c.nagCheck.AddMergeRequest(m, nagiosplugin.OK)
+	}
+
+	worst := nagiosplugin.OK
+	var overloaded []string
+	for _, uc := range countPerUser(mr, role) {
+		c.perUserThresholds.addPerfDatum(c.nagCheck, perUserPerfLabel(role, uc.Username), "", float64(uc.Count))
+
+		status := c.perUserThresholds.status(float64(uc.Count))
+		if status == nagiosplugin.OK {
+			continue
+		}
+		if status > worst {
+			worst = status
+		}
+		overloaded = append(overloaded, fmt.Sprintf("%s: %d (%s)", uc.Username, uc.Count, status))
+	}
+
+	if len(overloaded) == 0 {
+		c.nagCheck.AddResultf(nagiosplugin.OK, localize(c.cfg.Locale, "No overloaded %s across %d projects"), localize(c.cfg.Locale, role), len(projects))
+		return
+	}
+
+	c.nagCheck.AddResultf(worst, localize(c.cfg.Locale, "%d overloaded %ss across %d projects"), len(overloaded), localize(c.cfg.Locale, role), len(projects))
+	c.nagCheck.AddLongPluginOutput(fmt.Sprintf(localize(c.cfg.Locale, "Overloaded %ss:\n%s"), localize(c.cfg.Locale, role), strings.Join(overloaded, "\n")))
+}
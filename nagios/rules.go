@@ -0,0 +1,153 @@
+package nagios
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MergeRequestRule overrides the last update delays for the merge requests
+// it matches. Every criteria that is set must match for the rule to apply.
+type MergeRequestRule struct {
+	Name string `mapstructure:"name"`
+
+	// Matching criteria
+	Labels       []string `mapstructure:"labels"`        // merge request must carry all of these labels
+	TargetBranch string   `mapstructure:"target-branch"` // shell pattern, e.g. release/*
+	Author       string   `mapstructure:"author"`        // author username
+	Draft        *bool    `mapstructure:"draft"`
+	TitleRegexp  string   `mapstructure:"title-regexp"`
+
+	// Actions. Zero delays fallback on the global ones.
+	WarningLastUpdateDelay  time.Duration `mapstructure:"warning-last-update"`
+	CriticalLastUpdateDelay time.Duration `mapstructure:"critical-last-update"`
+	Ignore                  bool          `mapstructure:"ignore"`
+}
+
+type compiledMergeRequestRule struct {
+	MergeRequestRule
+	titleRegexp *regexp.Regexp
+}
+
+// compileRules validates the rules against the global delays and pre-compiles their regexps
+func compileRules(rules []MergeRequestRule, global mergeRequestDelays) ([]compiledMergeRequestRule, error) {
+	var compiled []compiledMergeRequestRule
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+
+		cr := compiledMergeRequestRule{
+			MergeRequestRule: rule,
+		}
+
+		if rule.TitleRegexp != "" {
+			re, err := regexp.Compile(rule.TitleRegexp)
+			if err != nil {
+				return nil, errors.Wrapf(err, "compiling title-regexp of rule %s", rule.Name)
+			}
+			cr.titleRegexp = re
+		}
+
+		if rule.TargetBranch != "" {
+			if _, err := path.Match(rule.TargetBranch, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid target-branch pattern of rule %s", rule.Name)
+			}
+		}
+
+		// a warning delay past the critical one would never warn
+		if delays := rule.delays(global); !rule.Ignore && delays.Warning > delays.Critical {
+			return nil, fmt.Errorf("rule %s: warning-last-update %s is greater than critical-last-update %s", rule.Name, delays.Warning, delays.Critical)
+		}
+
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
+
+func (r compiledMergeRequestRule) matches(m MergeRequest) bool {
+	for _, label := range r.Labels {
+		if !m.HasLabel(label) {
+			return false
+		}
+	}
+
+	if r.TargetBranch != "" {
+		if ok, _ := path.Match(r.TargetBranch, m.TargetBranch); !ok {
+			return false
+		}
+	}
+
+	if r.Author != "" && r.Author != m.Author {
+		return false
+	}
+
+	if r.Draft != nil && *r.Draft != m.Draft {
+		return false
+	}
+
+	if r.titleRegexp != nil && !r.titleRegexp.MatchString(m.Title) {
+		return false
+	}
+
+	return true
+}
+
+// mergeRequestDelays holds the delays a merge request is evaluated against
+type mergeRequestDelays struct {
+	Warning  time.Duration
+	Critical time.Duration
+	Ignore   bool
+}
+
+// delays returns the delays the rule sets, falling back on the global ones
+func (r MergeRequestRule) delays(global mergeRequestDelays) mergeRequestDelays {
+	delays := global
+	if r.WarningLastUpdateDelay != 0 {
+		delays.Warning = r.WarningLastUpdateDelay
+	}
+	if r.CriticalLastUpdateDelay != 0 {
+		delays.Critical = r.CriticalLastUpdateDelay
+	}
+	delays.Ignore = r.Ignore
+	return delays
+}
+
+// delaysFor returns the delays of the first rule matching m,
+// or the global ones if no rule matches
+func (c nagiosProbe) delaysFor(m MergeRequest) mergeRequestDelays {
+	delays := mergeRequestDelays{
+		Warning:  c.cfg.WarningLastUpdateDelay,
+		Critical: c.cfg.CriticalLastUpdateDelay,
+	}
+
+	for _, rule := range c.rules {
+		if !rule.matches(m) {
+			continue
+		}
+
+		delays = rule.delays(delays)
+
+		log.WithFields(log.Fields{
+			"merge-request": m.IID,
+			"rule":          rule.Name,
+			"warning":       delays.Warning,
+			"critical":      delays.Critical,
+			"ignore":        delays.Ignore,
+		}).Debug("rule applied to merge request")
+
+		return delays
+	}
+
+	log.WithFields(log.Fields{
+		"merge-request": m.IID,
+		"warning":       delays.Warning,
+		"critical":      delays.Critical,
+	}).Debug("no rule matched merge request, using global delays")
+
+	return delays
+}
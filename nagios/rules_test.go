@@ -0,0 +1,131 @@
+package nagios
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelaysFor(t *testing.T) {
+	h := time.Hour
+	yes, no := true, false
+	global := mergeRequestDelays{Warning: 6 * h, Critical: 24 * h}
+
+	rules, err := compileRules([]MergeRequestRule{
+		{Name: "security", Labels: []string{"security", "backend"}, WarningLastUpdateDelay: 2 * h, CriticalLastUpdateDelay: 4 * h},
+		{Name: "release", TargetBranch: "release/*", WarningLastUpdateDelay: time.Hour},
+		{Name: "bot", Author: "renovate-bot", Ignore: true},
+		{Name: "ready", Draft: &no, TitleRegexp: "^hotfix", CriticalLastUpdateDelay: 8 * h},
+		{Name: "drafts", Draft: &yes, WarningLastUpdateDelay: 48 * h, CriticalLastUpdateDelay: 96 * h},
+		// never reached by the security merge requests
+		{Name: "backend", Labels: []string{"backend"}, Ignore: true},
+	}, global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := nagiosProbe{cfg: ProbeConfig{WarningLastUpdateDelay: global.Warning, CriticalLastUpdateDelay: global.Critical}, rules: rules}
+
+	tests := []struct {
+		name string
+		mr   MergeRequest
+		want mergeRequestDelays
+	}{
+		{
+			name: "no rule matches",
+			mr:   MergeRequest{TargetBranch: "master", Author: "alice", Title: "fix"},
+			want: global,
+		},
+		{
+			name: "first match wins",
+			mr:   MergeRequest{Labels: []string{"backend", "security"}, TargetBranch: "release/1.0"},
+			want: mergeRequestDelays{Warning: 2 * h, Critical: 4 * h},
+		},
+		{
+			name: "every label is required",
+			mr:   MergeRequest{Labels: []string{"security"}, TargetBranch: "master"},
+			want: global,
+		},
+		{
+			name: "partial override falls back on the global critical delay",
+			mr:   MergeRequest{TargetBranch: "release/1.0"},
+			want: mergeRequestDelays{Warning: time.Hour, Critical: 24 * h},
+		},
+		{
+			name: "glob does not match nested branches",
+			mr:   MergeRequest{TargetBranch: "release/a/b"},
+			want: global,
+		},
+		{
+			name: "ignore",
+			mr:   MergeRequest{Author: "renovate-bot", TargetBranch: "master"},
+			want: mergeRequestDelays{Warning: 6 * h, Critical: 24 * h, Ignore: true},
+		},
+		{
+			name: "draft false matches ready merge requests",
+			mr:   MergeRequest{Title: "hotfix: crash", TargetBranch: "master"},
+			want: mergeRequestDelays{Warning: 6 * h, Critical: 8 * h},
+		},
+		{
+			name: "draft false does not match drafts",
+			mr:   MergeRequest{Title: "hotfix: crash", TargetBranch: "master", Draft: true},
+			want: mergeRequestDelays{Warning: 48 * h, Critical: 96 * h},
+		},
+		{
+			name: "later rule",
+			mr:   MergeRequest{Labels: []string{"backend"}, TargetBranch: "master"},
+			want: mergeRequestDelays{Warning: 6 * h, Critical: 24 * h, Ignore: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probe.delaysFor(tt.mr); got != tt.want {
+				t.Errorf("delaysFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	global := mergeRequestDelays{Warning: 6 * time.Hour, Critical: 24 * time.Hour}
+	tests := []struct {
+		name string
+		rule MergeRequestRule
+		want string
+	}{
+		{
+			name: "critical below the global warning",
+			rule: MergeRequestRule{Name: "security", CriticalLastUpdateDelay: 2 * time.Hour},
+			want: "rule security: warning-last-update 6h0m0s is greater than critical-last-update 2h0m0s",
+		},
+		{
+			name: "warning above the global critical",
+			rule: MergeRequestRule{WarningLastUpdateDelay: 48 * time.Hour},
+			want: "rule #1: warning-last-update 48h0m0s is greater than critical-last-update 24h0m0s",
+		},
+		{
+			name: "invalid title regexp",
+			rule: MergeRequestRule{Name: "wip", TitleRegexp: "("},
+			want: "compiling title-regexp of rule wip",
+		},
+		{
+			name: "invalid target branch pattern",
+			rule: MergeRequestRule{Name: "release", TargetBranch: "release/["},
+			want: "invalid target-branch pattern of rule release",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileRules([]MergeRequestRule{tt.rule}, global)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("compileRules() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// ignored merge requests are never evaluated against the delays
+	if _, err := compileRules([]MergeRequestRule{{CriticalLastUpdateDelay: time.Hour, Ignore: true}}, global); err != nil {
+		t.Errorf("compileRules() of an ignore rule returned %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagios-plugin-git-hosted-project-merge-requests/report"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+const (
+	// sinkMeasurement names the series pushed by the sinks
+	sinkMeasurement = "git_merge_requests"
+
+	textfileStatusMetric   = "git_merge_requests_check_status"
+	textfilePerfdataMetric = "git_merge_requests_check_perfdata"
+	textfileLastRunMetric  = "git_merge_requests_check_last_run_timestamp_seconds"
+)
+
+// textfileMetrics lists the gauges written by the textfile sink
+var textfileMetrics = []exporterMetric{
+	{textfileStatusMetric, "Nagios status code of the last check run."},
+	{textfilePerfdataMetric, "Performance data of the last check run."},
+	{textfileLastRunMetric, "Time of the last check run, in seconds since the epoch."},
+}
+
+// sinkPayload is what a check pushes to the perfdata sinks once evaluated
+type sinkPayload struct {
+	Project  string // project, group or comma separated projects checked
+	Mode     string
+	Status   nagiosplugin.Status
+	Perfdata []report.PerfDatum
+	Time     time.Time
+}
+
+// perfdataSink pushes the perfdata of a check to a metrics backend
+type perfdataSink interface {
+	Name() string
+	Send(ctx context.Context, p sinkPayload) error
+}
+
+// newPerfdataSinks returns the sinks enabled in cfg
+func newPerfdataSinks(cfg ProbeConfig) ([]perfdataSink, error) {
+	var sinks []perfdataSink
+
+	if cfg.GraphiteAddress != "" {
+		if _, _, err := net.SplitHostPort(cfg.GraphiteAddress); err != nil {
+			return nil, errors.Wrap(err, "parsing graphite address")
+		}
+		sinks = append(sinks, graphiteSink{address: cfg.GraphiteAddress, prefix: cfg.GraphitePrefix})
+	}
+
+	if cfg.InfluxDBURL != "" {
+		u, err := url.Parse(cfg.InfluxDBURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing influxdb url")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("influxdb url %q must be an http or https url", cfg.InfluxDBURL)
+		}
+		sinks = append(sinks, influxDBSink{url: cfg.InfluxDBURL, token: cfg.InfluxDBToken})
+	}
+
+	if cfg.TextfilePath != "" {
+		sinks = append(sinks, textfileSink{path: cfg.TextfilePath})
+	}
+
+	return sinks, nil
+}
+
+// sinkProject names what cfg checks, for the sinks to tag their series with
+func sinkProject(cfg ProbeConfig) string {
+	switch {
+	case cfg.Project != "":
+		return cfg.Project
+	case cfg.Group != "":
+		return cfg.Group
+	}
+	return strings.Join(cfg.Projects, ",")
+}
+
+// definedPerfdata returns the perfdata having a value
+func (p sinkPayload) definedPerfdata() []report.PerfDatum {
+	var defined []report.PerfDatum
+	for _, d := range p.Perfdata {
+		if d.Value != nil {
+			defined = append(defined, d)
+		}
+	}
+	return defined
+}
+
+var graphiteUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+// graphiteSink writes to a Graphite plaintext protocol endpoint
+type graphiteSink struct {
+	address string
+	prefix  string
+}
+
+func (s graphiteSink) Name() string {
+	return "graphite"
+}
+
+func (s graphiteSink) Send(ctx context.Context, p sinkPayload) error {
+	var path []string
+	if s.prefix != "" {
+		path = append(path, s.prefix)
+	}
+	path = append(path,
+		graphiteUnsafeChars.ReplaceAllString(p.Project, "_"),
+		graphiteUnsafeChars.ReplaceAllString(p.Mode, "_"),
+	)
+	prefix := strings.Join(path, ".")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s.status %d %d\n", prefix, p.Status, p.Time.Unix())
+	for _, d := range p.definedPerfdata() {
+		fmt.Fprintf(&buf, "%s.%s %g %d\n", prefix, graphiteUnsafeChars.ReplaceAllString(d.Label, "_"), *d.Value, p.Time.Unix())
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", s.address)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "writing to %s", s.address)
+	}
+	return nil
+}
+
+var influxDBEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxDBSink posts line protocol to an InfluxDB write endpoint
+type influxDBSink struct {
+	url   string
+	token string
+}
+
+func (s influxDBSink) Name() string {
+	return "influxdb"
+}
+
+func (s influxDBSink) Send(ctx context.Context, p sinkPayload) error {
+	fields := []string{fmt.Sprintf("status=%di", p.Status)}
+	for _, d := range p.definedPerfdata() {
+		fields = append(fields, fmt.Sprintf("%s=%g", influxDBEscaper.Replace(d.Label), *d.Value))
+	}
+	line := fmt.Sprintf("%s,project=%s,mode=%s %s %d\n",
+		sinkMeasurement,
+		influxDBEscaper.Replace(p.Project),
+		influxDBEscaper.Replace(p.Mode),
+		strings.Join(fields, ","),
+		p.Time.UnixNano(),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(line))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "posting to %s", s.url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("posting to %s: %s: %s", s.url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// textfileSink writes a file for the node_exporter textfile collector
+type textfileSink struct {
+	path string
+}
+
+func (s textfileSink) Name() string {
+	return "textfile"
+}
+
+func (s textfileSink) Send(ctx context.Context, p sinkPayload) error {
+	labels := [][2]string{{"project", p.Project}, {"mode", p.Mode}}
+	samples := []exporterSample{
+		{metric: textfileStatusMetric, labels: labels, value: float64(p.Status)},
+		{metric: textfileLastRunMetric, labels: labels, value: float64(p.Time.Unix())},
+	}
+	for _, d := range p.definedPerfdata() {
+		samples = append(samples, exporterSample{
+			metric: textfilePerfdataMetric,
+			labels: append(append([][2]string{}, labels...), [2]string{"label", d.Label}),
+			value:  *d.Value,
+		})
+	}
+
+	var buf bytes.Buffer
+	writeExporterSamples(&buf, textfileMetrics, samples)
+
+	// the collector must never read a partially written file
+	return writeFileAtomic(s.path, buf.Bytes())
+}
+
+// writeFileAtomic replaces file with data, readers see either the old or the new content
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "writing %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "writing %s", tmp.Name())
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrapf(err, "setting permissions of %s", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return errors.Wrapf(err, "renaming %s", tmp.Name())
+	}
+	return nil
+}
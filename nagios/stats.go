@@ -0,0 +1,55 @@
+package nagios
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// durationStats summarizes a set of durations
+type durationStats struct {
+	Count  int
+	Mean   time.Duration
+	Median time.Duration
+	P90    time.Duration
+	Max    time.Duration
+}
+
+func newDurationStats(durations []time.Duration) durationStats {
+	stats := durationStats{
+		Count: len(durations),
+	}
+	if len(durations) == 0 {
+		return stats
+	}
+
+	sorted := make([]float64, len(durations))
+	var sum float64
+	for i, d := range durations {
+		sorted[i] = float64(d)
+		sum += float64(d)
+	}
+	sort.Float64s(sorted)
+
+	stats.Mean = time.Duration(sum / float64(len(sorted)))
+	stats.Median = time.Duration(percentile(sorted, 50))
+	stats.P90 = time.Duration(percentile(sorted, 90))
+	stats.Max = time.Duration(sorted[len(sorted)-1])
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted values,
+// linearly interpolated between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
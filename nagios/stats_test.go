@@ -0,0 +1,77 @@
+package nagios
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewDurationStats(t *testing.T) {
+	h := time.Hour
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      durationStats
+	}{
+		{
+			name: "empty",
+			want: durationStats{},
+		},
+		{
+			name:      "single",
+			durations: []time.Duration{3 * h},
+			want:      durationStats{Count: 1, Mean: 3 * h, Median: 3 * h, P90: 3 * h, Max: 3 * h},
+		},
+		{
+			name:      "two",
+			durations: []time.Duration{4 * h, 2 * h},
+			want:      durationStats{Count: 2, Mean: 3 * h, Median: 3 * h, P90: 3*h + 48*time.Minute, Max: 4 * h},
+		},
+		{
+			name:      "unsorted",
+			durations: []time.Duration{7 * h, 1 * h, 10 * h, 3 * h, 5 * h, 2 * h, 9 * h, 4 * h, 8 * h, 6 * h},
+			want:      durationStats{Count: 10, Mean: 5*h + 30*time.Minute, Median: 5*h + 30*time.Minute, P90: 9*h + 6*time.Minute, Max: 10 * h},
+		},
+		{
+			name:      "odd",
+			durations: []time.Duration{1 * h, 100 * h, 2 * h},
+			want:      durationStats{Count: 3, Mean: 34*h + 20*time.Minute, Median: 2 * h, P90: 80*h + 24*time.Minute, Max: 100 * h},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDurationStats(tt.durations); got != tt.want {
+				t.Errorf("newDurationStats(%v) = %+v, want %+v", tt.durations, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40, 50}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{25, 20},
+		{50, 30},
+		{60, 34},
+		{90, 46},
+		{100, 50},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v, %v) = %v, want %v", sorted, tt.p, got, tt.want)
+		}
+	}
+
+	if got := percentile(nil, 50); !math.IsNaN(got) {
+		t.Errorf("percentile of an empty set = %v, want NaN", got)
+	}
+	if got := percentile([]float64{42}, 90); got != 42 {
+		t.Errorf("percentile of a single value = %v, want 42", got)
+	}
+}
@@ -0,0 +1,24 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// parseStatus converts a user supplied severity (ok, warning, critical, unknown)
+// into a nagiosplugin.Status
+func parseStatus(s string) (nagiosplugin.Status, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "ok":
+		return nagiosplugin.OK, nil
+	case "warning", "warn":
+		return nagiosplugin.WARNING, nil
+	case "critical", "crit":
+		return nagiosplugin.CRITICAL, nil
+	case "unknown":
+		return nagiosplugin.UNKNOWN, nil
+	}
+	return nagiosplugin.UNKNOWN, fmt.Errorf("invalid severity %q (expected one of ok, warning, critical, unknown)", s)
+}
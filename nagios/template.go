@@ -0,0 +1,60 @@
+package nagios
+
+import (
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// templateFuncs returns the helpers of every template rendering merge requests,
+// humanize wording the durations in locale
+func templateFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"humanize": func(d time.Duration) string { return HumanizeDuration(d, locale) },
+		"truncate": truncate,
+	}
+}
+
+// truncate shortens s to n characters, ellipsis included.
+// Its argument order suits pipelines: {{ .Title | truncate 30 }}
+func truncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
+
+// MergeRequestTemplateData is what the templates rendering
+// a merge request are executed with
+type MergeRequestTemplateData struct {
+	MergeRequest
+	LastActivity time.Duration // since the last activity, to the minute
+	Age          time.Duration // since the creation, to the minute
+}
+
+func newMergeRequestTemplateData(m MergeRequest, now time.Time) MergeRequestTemplateData {
+	return MergeRequestTemplateData{
+		MergeRequest: m,
+		LastActivity: now.Sub(m.UpdatedAt).Truncate(time.Minute),
+		Age:          now.Sub(m.CreatedAt).Truncate(time.Minute),
+	}
+}
+
+// sampleMergeRequest is the merge request templates are validated with,
+// unknown fields are only reported on execution
+func sampleMergeRequest(now time.Time) MergeRequest {
+	return MergeRequest{
+		ID:           1001,
+		IID:          1,
+		Project:      "group/project",
+		Title:        "Sample merge request",
+		Author:       "author",
+		WebURL:       "https://git.example.com/group/project/-/merge_requests/1",
+		TargetBranch: "master",
+		SourceBranch: "feature",
+		CreatedAt:    now.Add(-48 * time.Hour),
+		UpdatedAt:    now.Add(-24 * time.Hour),
+		State:        MergeRequestStateOpened,
+	}
+}
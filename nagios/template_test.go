@@ -0,0 +1,28 @@
+package nagios
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{10, "Fix the build", "Fix the b…"},
+		{13, "Fix the build", "Fix the build"},
+		{12, "Fix the build", "Fix the bui…"},
+		{1, "Fix", "…"},
+		{0, "Fix the build", "Fix the build"},
+		{-1, "Fix the build", "Fix the build"},
+		{5, "", ""},
+		// characters rather than bytes
+		{6, "Corrigé l'été", "Corri…"},
+		{4, "été", "été"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
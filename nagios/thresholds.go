@@ -0,0 +1,89 @@
+package nagios
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/riton/nagiosplugin/v2"
+)
+
+// thresholds is a pair of warning / critical nagios ranges.
+// See https://nagios-plugins.org/doc/guidelines.html#THRESHOLDFORMAT
+type thresholds struct {
+	warning  *nagiosplugin.Range
+	critical *nagiosplugin.Range
+}
+
+// parseThresholds parses the warning and critical ranges.
+// An empty range is never alerting.
+func parseThresholds(warning, critical string) (thresholds, error) {
+	var t thresholds
+	var err error
+
+	if warning != "" {
+		if t.warning, err = nagiosplugin.ParseRange(warning); err != nil {
+			return t, errors.Wrapf(err, "parsing warning range %q", warning)
+		}
+	}
+
+	if critical != "" {
+		if t.critical, err = nagiosplugin.ParseRange(critical); err != nil {
+			return t, errors.Wrapf(err, "parsing critical range %q", critical)
+		}
+	}
+
+	return t, nil
+}
+
+// status returns the nagios status of value
+func (t thresholds) status(value float64) nagiosplugin.Status {
+	if t.critical != nil && t.critical.Check(value) {
+		return nagiosplugin.CRITICAL
+	}
+	if t.warning != nil && t.warning.Check(value) {
+		return nagiosplugin.WARNING
+	}
+	return nagiosplugin.OK
+}
+
+// addPerfDatum adds value to the check perfdata along with the thresholds
+func (t thresholds) addPerfDatum(check *Check, label, unit string, value float64) {
+	v, err := nagiosplugin.NewFloatPerfDatumValue(value)
+	if err != nil {
+		check.AddPerfDatum(label, unit, nagiosplugin.NewUndeterminedPerfDatumValue(), t.warning, t.critical, nil, nil)
+		return
+	}
+	check.AddPerfDatum(label, unit, v, t.warning, t.critical, nil, nil)
+}
+
+// parseMetricThresholds parses the warning and critical ranges attached to metrics by name.
+// Every metric must be one of known.
+func parseMetricThresholds(warning, critical map[string]string, known []string) (map[string]thresholds, error) {
+	isKnown := make(map[string]bool)
+	for _, k := range known {
+		isKnown[k] = true
+	}
+
+	names := make(map[string]bool)
+	for name := range warning {
+		names[name] = true
+	}
+	for name := range critical {
+		names[name] = true
+	}
+
+	metricThresholds := make(map[string]thresholds)
+	for name := range names {
+		if !isKnown[name] {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+
+		t, err := parseThresholds(warning[name], critical[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "metric %s", name)
+		}
+		metricThresholds[name] = t
+	}
+
+	return metricThresholds, nil
+}
@@ -0,0 +1,105 @@
+package nagios
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/riton/nagiosplugin/v2"
+)
+
+func TestThresholdsStatus(t *testing.T) {
+	tests := []struct {
+		warning, critical string
+		value             float64
+		want              nagiosplugin.Status
+	}{
+		{"", "", 1000, nagiosplugin.OK},
+		{"10", "20", 5, nagiosplugin.OK},
+		{"10", "20", 10, nagiosplugin.OK},
+		{"10", "20", 11, nagiosplugin.WARNING},
+		{"10", "20", 21, nagiosplugin.CRITICAL},
+		{"", "20", 15, nagiosplugin.OK},
+		{"10:", "", 5, nagiosplugin.WARNING},
+		{"@5:10", "", 7, nagiosplugin.WARNING},
+		{"@5:10", "", 11, nagiosplugin.OK},
+	}
+
+	for _, tt := range tests {
+		th, err := parseThresholds(tt.warning, tt.critical)
+		if err != nil {
+			t.Errorf("parseThresholds(%q, %q) returned %v", tt.warning, tt.critical, err)
+			continue
+		}
+		if got := th.status(tt.value); got != tt.want {
+			t.Errorf("thresholds %q/%q: status(%v) = %s, want %s", tt.warning, tt.critical, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseMetricThresholds(t *testing.T) {
+	known := []string{OpenedMergeRequestsMetric, OldestMergeRequestMetric}
+
+	tests := []struct {
+		name              string
+		warning, critical map[string]string
+		want              []string // metrics having thresholds
+		wantErr           string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:     "warning and critical",
+			warning:  map[string]string{OpenedMergeRequestsMetric: "10"},
+			critical: map[string]string{OpenedMergeRequestsMetric: "20", OldestMergeRequestMetric: "86400"},
+			want:     []string{OpenedMergeRequestsMetric, OldestMergeRequestMetric},
+		},
+		{
+			name:    "unknown warning metric",
+			warning: map[string]string{"opened_issues": "10"},
+			wantErr: `unknown metric "opened_issues"`,
+		},
+		{
+			name:     "unknown critical metric",
+			critical: map[string]string{OpenedMergeRequestsMetric + "_typo": "10"},
+			wantErr:  `unknown metric "opened_merge_requests_typo"`,
+		},
+		{
+			name:    "invalid range",
+			warning: map[string]string{OldestMergeRequestMetric: "ten"},
+			wantErr: `metric oldest_merge_request: parsing warning range "ten"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMetricThresholds(tt.warning, tt.critical, known)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseMetricThresholds() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMetricThresholds() returned %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseMetricThresholds() = %d metrics, want %v", len(got), tt.want)
+			}
+			for _, name := range tt.want {
+				if _, ok := got[name]; !ok {
+					t.Errorf("parseMetricThresholds() is missing %s", name)
+				}
+			}
+		})
+	}
+
+	// a metric having only a critical range keeps a nil warning
+	got, err := parseMetricThresholds(nil, map[string]string{OpenedMergeRequestsMetric: "20"}, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th := got[OpenedMergeRequestsMetric]; th.warning != nil || th.critical == nil {
+		t.Errorf("thresholds = %+v, want a critical range only", th)
+	}
+}
@@ -0,0 +1,72 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/riton/nagiosplugin/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// mergedSince returns the merge requests merged after since.
+// Providers can only select merge requests on their last update, a merge request
+// merged before since but updated afterwards must not be counted
+func mergedSince(mr []MergeRequest, since time.Time) []MergeRequest {
+	var merged []MergeRequest
+	for _, m := range mr {
+		if m.MergedAt.Before(since) {
+			log.WithFields(log.Fields{
+				"merge-request": m.IID,
+				"merged-at":     m.MergedAt,
+			}).Debug("merge request merged before the lookback window")
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// checkThroughput reports the number of merge requests merged during the lookback
+// window and how long they took to get reviewed and merged
+func (c nagiosProbe) checkThroughput(mrChecker GitMergeRequestChecker) {
+	since := time.Now().Add(-c.cfg.Lookback)
+
+	mr, _, err := c.listMergeRequests(mrChecker, ListMergeRequestsOptions{
+		State:        MergeRequestStateMerged,
+		TargetBranch: c.cfg.TargetBranch,
+		UpdatedAfter: since,
+	}, c.cfg.Project)
+	if err != nil {
+		c.nagCheck.Criticalf("%s", err)
+		return
+	}
+	c.addTotalDurationPerfDatum()
+
+	in := metricInput{merged: mergedSince(mr, since)}
+
+	c.checkMetrics(in,
+		MergedMergeRequestsMetric,
+		MergedPerDayMetric,
+		MedianTimeToMergeMetric,
+		P90TimeToMergeMetric,
+		MedianTimeToFirstReviewMetric,
+		P90TimeToFirstReviewMetric,
+	)
+
+	var longOutput []string
+	for _, m := range in.merged {
+		longOutput = append(longOutput, fmt.Sprintf(localize(c.cfg.Locale, "Merge request %d merged after %s"), m.IID, HumanizeDuration(m.TimeToMerge(), c.cfg.Locale)))
+	}
+	if len(longOutput) > 0 {
+		c.nagCheck.AddLongPluginOutput(strings.Join(longOutput, "\n"))
+	}
+
+	if len(in.merged) == 0 {
+		c.nagCheck.AddResultf(nagiosplugin.OK, localize(c.cfg.Locale, "No merge requests merged in the last %s"), HumanizeDuration(c.cfg.Lookback, c.cfg.Locale))
+		return
+	}
+
+	c.nagCheck.AddResultf(nagiosplugin.OK, localize(c.cfg.Locale, "%d merge requests merged in the last %s, median time to merge %s"),
+		len(in.merged), HumanizeDuration(c.cfg.Lookback, c.cfg.Locale), HumanizeDuration(in.timesToMerge().Median, c.cfg.Locale))
+}
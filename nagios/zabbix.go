@@ -0,0 +1,184 @@
+package nagios
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ZabbixDiscovery is a Zabbix low-level discovery document
+type ZabbixDiscovery struct {
+	Data []map[string]string `json:"data"`
+}
+
+// DiscoverZabbix lists the projects of the configured group, list of projects
+// or project, and their target branches: the configured one, or if no target
+// branch is configured an empty one standing for every branch, followed by
+// the ones the opened merge requests target. A project is thus discovered
+// even without opened merge requests, so that Zabbix keeps its items.
+func DiscoverZabbix(cfg ProbeConfig) (ZabbixDiscovery, error) {
+	discovery := ZabbixDiscovery{Data: []map[string]string{}}
+
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.initSelection(); err != nil {
+		return discovery, err
+	}
+
+	mrChecker, err := newGitMergeRequestChecker(cfg, mrCheckerOptions{})
+	if err != nil {
+		return discovery, errors.Wrapf(err, "initializing %s checker", cfg.GitProvider)
+	}
+	projects, err := resolveProjects(cfg, mrChecker)
+	if err != nil {
+		return discovery, err
+	}
+
+	for _, project := range projects {
+		branches := []string{cfg.TargetBranch}
+		if cfg.TargetBranch == "" {
+			mr, err := mrChecker.ListMergeRequests(project, ListMergeRequestsOptions{
+				State: MergeRequestStateOpened,
+			})
+			if err != nil {
+				return discovery, errors.Wrapf(err, "listing merge requests of %s", project)
+			}
+			mr, _ = probe.selectMergeRequests(mr)
+			branches = append(branches, targetBranches(mr)...)
+		}
+
+		data := newPassiveTemplateData(project, cfg.Mode)
+		for _, branch := range branches {
+			discovery.Data = append(discovery.Data, map[string]string{
+				"{#PROJECT}":       data.Project,
+				"{#PROJECT_NAME}":  data.Name,
+				"{#NAMESPACE}":     data.Namespace,
+				"{#TARGET_BRANCH}": branch,
+			})
+		}
+	}
+
+	return discovery, nil
+}
+
+// targetBranches returns the sorted distinct target branches of mr
+func targetBranches(mr []MergeRequest) []string {
+	seen := make(map[string]bool)
+	var branches []string
+	for _, m := range mr {
+		if !seen[m.TargetBranch] {
+			seen[m.TargetBranch] = true
+			branches = append(branches, m.TargetBranch)
+		}
+	}
+	sort.Strings(branches)
+	return branches
+}
+
+// MetricValue computes a single registered metric over the configured project,
+// in the first check mode able to compute it
+func MetricValue(cfg ProbeConfig, name string) (float64, error) {
+	d, ok := lookupMetric(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown metric %q", name)
+	}
+
+	cfg.Mode = d.Modes[0]
+	probe := nagiosProbe{cfg: cfg}
+	if err := probe.init(); err != nil {
+		return 0, err
+	}
+
+	in, err := probe.fetchMetricInput(d)
+	if err != nil {
+		return 0, err
+	}
+	in.now = time.Now()
+	in.cfg = cfg
+
+	value, ok := d.value(in)
+	if !ok {
+		return 0, fmt.Errorf("metric %s cannot be computed: not enough data", name)
+	}
+	return value, nil
+}
+
+// fetchMetricInput fetches what the metric d is computed from
+func (c nagiosProbe) fetchMetricInput(d MetricDefinition) (metricInput, error) {
+	var in metricInput
+
+	if d.availableIn(IssuesMode) {
+		issueChecker, err := newGitIssueChecker(c.cfg)
+		if err != nil {
+			return in, errors.Wrapf(err, "initializing %s checker", c.cfg.GitProvider)
+		}
+		in.issues, err = issueChecker.CheckIssues(c.cfg.Project, ListIssuesOptions{
+			Labels:    c.cfg.IssueLabels,
+			Milestone: c.cfg.IssueMilestone,
+			Assignee:  c.cfg.IssueAssignee,
+		})
+		if err != nil {
+			return in, errors.Wrapf(err, "listing issues of %s", c.cfg.Project)
+		}
+		return in, nil
+	}
+
+	// only fetch the expensive details the metric needs
+	opts := c.mrCheckerOptions()
+	opts.WithMergeability = opts.WithMergeability || d.Name == ConflictingMergeRequestsMetric
+	opts.WithPipelineStatus = opts.WithPipelineStatus || d.Name == FailedPipelineMergeRequestsMetric
+	opts.WithCloser = opts.WithCloser || d.Name == BotClosedMergeRequestsMetric
+	mrChecker, err := newGitMergeRequestChecker(c.cfg, opts)
+	if err != nil {
+		return in, errors.Wrapf(err, "initializing %s checker", c.cfg.GitProvider)
+	}
+
+	if d.availableIn(BranchesMode) {
+		in.branches, err = c.fetchBranches(mrChecker, c.cfg.Project)
+		return in, err
+	}
+
+	list := func(state string, updatedAfter time.Time) ([]MergeRequest, error) {
+		mr, err := mrChecker.ListMergeRequests(c.cfg.Project, ListMergeRequestsOptions{
+			State:        state,
+			TargetBranch: c.cfg.TargetBranch,
+			UpdatedAfter: updatedAfter,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing %s merge requests of %s", state, c.cfg.Project)
+		}
+		log.WithFields(log.Fields{
+			"state":          state,
+			"merge-requests": mr,
+		}).Debug("merge requests fetched successfully")
+		mr, _ = c.selectMergeRequests(mr)
+		return mr, nil
+	}
+
+	since := time.Now().Add(-c.cfg.Lookback)
+	switch {
+	case d.availableIn(MergeRequestsMode):
+		in.opened, err = list(MergeRequestStateOpened, time.Time{})
+	case d.availableIn(ThroughputMode):
+		if in.merged, err = list(MergeRequestStateMerged, since); err == nil {
+			in.merged = mergedSince(in.merged, since)
+		}
+	case d.availableIn(AbandonedMode):
+		if in.closed, err = list(MergeRequestStateClosed, since); err != nil {
+			break
+		}
+		in.closed = closedSince(in.closed, since)
+		for _, m := range in.closed {
+			if c.closedByBot(m) {
+				in.botClosed = append(in.botClosed, m)
+			}
+		}
+		if in.merged, err = list(MergeRequestStateMerged, since); err == nil {
+			in.merged = mergedSince(in.merged, since)
+		}
+	}
+
+	return in, err
+}
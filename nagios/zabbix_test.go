@@ -0,0 +1,80 @@
+package nagios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiscoverZabbix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v4/":
+			// the Gitlab client probes the rate limits when created
+			http.NotFound(w, r)
+		case r.URL.Path == "/api/v4/projects/group/a/merge_requests":
+			at := `"created_at":"2021-06-01T12:00:00Z","updated_at":"2021-06-01T12:00:00Z"`
+			w.Write([]byte(`[{"id":1,"iid":1,"target_branch":"main",` + at + `},` +
+				`{"id":2,"iid":2,"target_branch":"develop",` + at + `},` +
+				`{"id":3,"iid":3,"target_branch":"main",` + at + `}]`))
+		case r.URL.Path == "/api/v4/projects/group/b/merge_requests":
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	row := func(project, name, branch string) map[string]string {
+		return map[string]string{"{#PROJECT}": project, "{#PROJECT_NAME}": name, "{#NAMESPACE}": "group", "{#TARGET_BRANCH}": branch}
+	}
+	tests := []struct {
+		name         string
+		targetBranch string
+		want         []map[string]string
+	}{
+		{
+			name: "without target branch",
+			want: []map[string]string{
+				row("group/a", "a", ""),
+				row("group/a", "a", "develop"),
+				row("group/a", "a", "main"),
+				// without opened merge requests
+				row("group/b", "b", ""),
+			},
+		},
+		{
+			name:         "with target branch",
+			targetBranch: "main",
+			want: []map[string]string{
+				row("group/a", "a", "main"),
+				row("group/b", "b", "main"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery, err := DiscoverZabbix(ProbeConfig{
+				APIEndpoint:             srv.URL,
+				GitProvider:             GitlabGitProvider,
+				Projects:                []string{"group/a", "group/b"},
+				TargetBranch:            tt.targetBranch,
+				Mode:                    MergeRequestsMode,
+				Timeout:                 5 * time.Second,
+				WarningLastUpdateDelay:  6 * time.Hour,
+				CriticalLastUpdateDelay: 24 * time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(discovery.Data, tt.want) {
+				t.Errorf("discovery = %v\nwant        %v", discovery.Data, tt.want)
+			}
+		})
+	}
+}
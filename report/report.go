@@ -0,0 +1,62 @@
+// Package report defines the machine readable output of the checks
+// (--output json). Fields are only ever added to these types, consumers
+// can rely on the existing ones as long as SchemaVersion does not change.
+package report
+
+import "time"
+
+// SchemaVersion is bumped on any backward incompatible change of Report
+const SchemaVersion = 1
+
+// Report is the outcome of a check
+type Report struct {
+	SchemaVersion int `json:"schema_version"`
+	// Status is one of OK, WARNING, CRITICAL or UNKNOWN
+	Status string `json:"status"`
+	// ExitCode is the nagios plugin exit code matching Status
+	ExitCode int `json:"exit_code"`
+	// Summary is the first line of the nagios plugin output, without the status
+	Summary       string         `json:"summary"`
+	LongOutput    string         `json:"long_output,omitempty"`
+	Results       []Result       `json:"results"`
+	Perfdata      []PerfDatum    `json:"perfdata"`
+	MergeRequests []MergeRequest `json:"merge_requests,omitempty"`
+}
+
+// Result is one of the results the service status is computed from
+type Result struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// PerfDatum is a nagios performance data
+type PerfDatum struct {
+	Label string `json:"label"`
+	Unit  string `json:"unit,omitempty"`
+	// Value is nil when it could not be determined ("U" in the nagios output)
+	Value *float64 `json:"value"`
+	// Warning and Critical use the nagios range syntax
+	Warning  string   `json:"warning,omitempty"`
+	Critical string   `json:"critical,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// MergeRequest is an evaluated merge request and its individual status
+type MergeRequest struct {
+	ID           int       `json:"id"`
+	IID          int       `json:"iid"`
+	Project      string    `json:"project"`
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	TargetBranch string    `json:"target_branch"`
+	WebURL       string    `json:"web_url"`
+	Labels       []string  `json:"labels"`
+	Draft        bool      `json:"draft"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// Status is one of OK, WARNING, CRITICAL or UNKNOWN
+	Status string `json:"status"`
+	// Reasons explains a Status other than OK
+	Reasons []string `json:"reasons,omitempty"`
+}